@@ -28,25 +28,63 @@ type ArrayType struct {
 
 func (t ArrayType) _type() {}
 
+type OptionalType struct {
+	UnderlyingType Type
+}
+
+func (t OptionalType) _type() {}
+
+type MapType struct {
+	KeyType   Type
+	ValueType Type
+}
+
+func (t MapType) _type() {}
+
+type ResultType struct {
+	ValueType Type
+	ErrType   Type
+}
+
+func (t ResultType) _type() {}
+
 type FuncType struct {
 	ReturnType Type
 	ParamTypes []Type
+	Variadic   bool
 }
 
 func (t FuncType) _type() {}
 
+type TupleType struct {
+	ElemTypes []Type
+}
+
+func (t TupleType) _type() {}
+
 type BoolLiteralExpr struct {
 	Value bool
 }
 
 func (e BoolLiteralExpr) expr() {}
 
+type NoneLiteralExpr struct{}
+
+func (e NoneLiteralExpr) expr() {}
+
 type StringLiteralExpr struct {
 	Value string
 }
 
 func (e StringLiteralExpr) expr() {}
 
+type InterpolatedStringExpr struct {
+	Parts []string
+	Exprs []Expr
+}
+
+func (e InterpolatedStringExpr) expr() {}
+
 type IdentExpr struct {
 	Value string
 }
@@ -92,6 +130,12 @@ type GroupExpr struct {
 
 func (e GroupExpr) expr() {}
 
+type TupleExpr struct {
+	Elems []Expr
+}
+
+func (e TupleExpr) expr() {}
+
 type VarDeclStmt struct {
 	Var     TypedIdent
 	InitVal Expr
@@ -99,14 +143,35 @@ type VarDeclStmt struct {
 
 func (s VarDeclStmt) stmt() {}
 
+type TupleDeclStmt struct {
+	Vars    []TypedIdent
+	InitVal Expr
+}
+
+func (s TupleDeclStmt) stmt() {}
+
 type TypedIdent struct {
 	Name string
 	Type Type
 }
 
+type Parameter struct {
+	Name     string
+	Type     Type
+	Default  Expr
+	Variadic bool
+}
+
+type Attribute struct {
+	Name string
+	Args []Expr
+}
+
 type FuncDeclStmt struct {
+	Attributes []Attribute
+	Extern     bool
 	Name       string
-	Parameters []TypedIdent
+	Parameters []Parameter
 	ReturnType Type
 	Body       BlockStmt
 }
@@ -120,15 +185,66 @@ type FuncCallExpr struct {
 
 func (e FuncCallExpr) expr() {}
 
+type SpreadExpr struct {
+	Expr Expr
+}
+
+func (e SpreadExpr) expr() {}
+
+type NamedArgExpr struct {
+	Name  string
+	Value Expr
+}
+
+func (e NamedArgExpr) expr() {}
+
 type StructDeclStmt struct {
-	Name    string
-	Members []TypedIdent
+	Attributes    []Attribute
+	Name          string
+	Members       []StructMember
+	Methods       []FuncDeclStmt
+	NestedStructs []StructDeclStmt
 }
 
 func (s StructDeclStmt) stmt() {}
 
+type StructMember struct {
+	Attributes []Attribute
+	Name       string
+	Type       Type
+	Default    Expr
+	Embedded   bool
+}
+
+type InterfaceDeclStmt struct {
+	Name    string
+	Methods []TypedIdent
+}
+
+func (s InterfaceDeclStmt) stmt() {}
+
+type TypeDeclStmt struct {
+	Name     string
+	Type     Type
+	Distinct bool
+}
+
+func (s TypeDeclStmt) stmt() {}
+
+type MapEntry struct {
+	Key   Expr
+	Value Expr
+}
+
+type MapLiteralExpr struct {
+	Entries []MapEntry
+}
+
+func (e MapLiteralExpr) expr() {}
+
 type StructLiteralExpr struct {
 	Struct  Expr
+	Base    Expr
 	Members []MemberAssignExpr
 }
 
@@ -141,6 +257,19 @@ type StructMemberExpr struct {
 
 func (e StructMemberExpr) expr() {}
 
+type OptionalMemberExpr struct {
+	Struct Expr
+	Member IdentExpr
+}
+
+func (e OptionalMemberExpr) expr() {}
+
+type TryExpr struct {
+	Expr Expr
+}
+
+func (e TryExpr) expr() {}
+
 type ArrayIndexExpr struct {
 	Array Expr
 	Index Expr
@@ -148,6 +277,14 @@ type ArrayIndexExpr struct {
 
 func (e ArrayIndexExpr) expr() {}
 
+type SliceExpr struct {
+	Array Expr
+	Start Expr
+	End   Expr
+}
+
+func (e SliceExpr) expr() {}
+
 type IfStmt struct {
 	Cond Expr
 	Then Stmt
@@ -156,6 +293,14 @@ type IfStmt struct {
 
 func (s IfStmt) stmt() {}
 
+type IfExpr struct {
+	Cond Expr
+	Then Expr
+	Else Expr
+}
+
+func (e IfExpr) expr() {}
+
 type ForStmt struct {
 	Init Stmt
 	Cond Expr
@@ -165,6 +310,22 @@ type ForStmt struct {
 
 func (s ForStmt) stmt() {}
 
+type ForEachStmt struct {
+	Index    string
+	Elem     string
+	Iterable Expr
+	Body     BlockStmt
+}
+
+func (s ForEachStmt) stmt() {}
+
+type RangeExpr struct {
+	Start Expr
+	End   Expr
+}
+
+func (e RangeExpr) expr() {}
+
 type AssignExpr struct {
 	Assigne       Expr
 	Operator      lexer.Token
@@ -185,3 +346,30 @@ type ReturnStmt struct {
 }
 
 func (s ReturnStmt) stmt() {}
+
+type DeferStmt struct {
+	Expr Expr
+}
+
+func (s DeferStmt) stmt() {}
+
+type StaticAssertStmt struct {
+	Cond    Expr
+	Message Expr
+}
+
+func (s StaticAssertStmt) stmt() {}
+
+// Line and column numbers start from 1. The zero Location means that the
+// parser was not given the token spans needed to locate the statement.
+type Location struct {
+	Line   int
+	Column int
+}
+
+type AssertStmt struct {
+	Cond     Expr
+	Location Location
+}
+
+func (s AssertStmt) stmt() {}
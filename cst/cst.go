@@ -0,0 +1,84 @@
+// Package cst is a concrete syntax tree: the parse of a program that keeps
+// every token, including punctuation, whitespace and comments, so that the
+// exact source can be reproduced from it.
+package cst
+
+import (
+	"github.com/ruistola/compiler-proto/lexer"
+	"strings"
+)
+
+// Element is either a Node or a Token.
+type Element interface {
+	// The exact source text covered by the element, including trivia.
+	Source() string
+}
+
+// Whitespace or a comment.
+type Trivia struct {
+	Type lexer.TokenType
+	Text string
+	Span lexer.Span
+}
+
+// Token is a significant token together with the trivia preceding it. Text
+// is the exact source text of the token, which for string tokens differs
+// from the Value the lexer gives them.
+type Token struct {
+	Type          lexer.TokenType
+	Text          string
+	Span          lexer.Span
+	LeadingTrivia []Trivia
+}
+
+func (t Token) Source() string {
+	var sb strings.Builder
+	for _, trivia := range t.LeadingTrivia {
+		sb.WriteString(trivia.Text)
+	}
+	sb.WriteString(t.Text)
+	return sb.String()
+}
+
+// Node is a statement, expression or type. Kind is the name of the ast type
+// it lowers to, e.g. "FuncDeclStmt"; parser.LowerCST builds the ast itself
+// from the current tokens of the tree. The root of a tree has Kind "Program",
+// and its last child is the EOF token carrying the trivia at the end of the
+// source.
+type Node struct {
+	Kind     string
+	Children []Element
+}
+
+func (n Node) Source() string {
+	var sb strings.Builder
+	for _, child := range n.Children {
+		sb.WriteString(child.Source())
+	}
+	return sb.String()
+}
+
+// Tokens returns the significant tokens of the node in source order.
+func (n Node) Tokens() []Token {
+	tokens := []Token{}
+	for _, child := range n.Children {
+		switch c := child.(type) {
+		case Token:
+			tokens = append(tokens, c)
+		case Node:
+			tokens = append(tokens, c.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// Nodes returns the child nodes of the node, skipping its tokens.
+func (n Node) Nodes() []Node {
+	nodes := []Node{}
+	for _, child := range n.Children {
+		if node, ok := child.(Node); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
@@ -14,6 +14,9 @@ const (
 	COMMENT              // pseudotype that will not participate in AST but may in the future be kept as metadata
 	NUMBER
 	STRING
+	STRING_HEAD   // text of an interpolated string literal up to the first embedded expression
+	STRING_MIDDLE // text of an interpolated string literal between two embedded expressions
+	STRING_TAIL   // text of an interpolated string literal after the last embedded expression
 	IDENTIFIER
 
 	// Grouping & Braces
@@ -41,7 +44,13 @@ const (
 	AND
 
 	// Symbols
+	AT
 	DOT
+	DOT_DOT
+	ELLIPSIS
+	QUESTION
+	QUESTION_DOT
+	QUESTION_QUESTION
 	SEMI_COLON
 	COLON
 	COMMA
@@ -60,13 +69,22 @@ const (
 	// Reserved Keywords
 	LET
 	STRUCT
+	INTERFACE
+	TYPE
+	NEWTYPE
 	TRUE
 	FALSE
+	NONE
 	FUNC
+	EXTERN
 	IF
 	ELSE
 	FOR
+	IN
 	RETURN
+	DEFER
+	STATIC_ASSERT
+	ASSERT
 
 	// Misc
 	NUM_TOKENS
@@ -82,7 +100,6 @@ var tokenPatterns []tokenPattern = []tokenPattern{
 	{WORD, regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)},
 	{COMMENT, regexp.MustCompile(`^\/\/.*`)},
 	{NUMBER, regexp.MustCompile(`^[0-9]+(\.[0-9]+)?`)},
-	{STRING, regexp.MustCompile(`^"[^"]*"`)},
 	{OPEN_BRACKET, regexp.MustCompile(`^\[`)},
 	{CLOSE_BRACKET, regexp.MustCompile(`^\]`)},
 	{OPEN_CURLY, regexp.MustCompile(`^\{`)},
@@ -99,6 +116,12 @@ var tokenPatterns []tokenPattern = []tokenPattern{
 	{GREATER, regexp.MustCompile(`^>`)},
 	{OR, regexp.MustCompile(`^\|\|`)},
 	{AND, regexp.MustCompile(`^&&`)},
+	{AT, regexp.MustCompile(`^@`)},
+	{QUESTION_QUESTION, regexp.MustCompile(`^\?\?`)},
+	{QUESTION_DOT, regexp.MustCompile(`^\?\.`)},
+	{QUESTION, regexp.MustCompile(`^\?`)},
+	{ELLIPSIS, regexp.MustCompile(`^\.\.\.`)},
+	{DOT_DOT, regexp.MustCompile(`^\.\.`)},
 	{DOT, regexp.MustCompile(`^\.`)},
 	{SEMI_COLON, regexp.MustCompile(`^;`)},
 	{COLON, regexp.MustCompile(`^:`)},
@@ -113,15 +136,24 @@ var tokenPatterns []tokenPattern = []tokenPattern{
 }
 
 var reservedKeywords map[string]TokenType = map[string]TokenType{
-	"let":    LET,
-	"struct": STRUCT,
-	"true":   TRUE,
-	"false":  FALSE,
-	"func":   FUNC,
-	"if":     IF,
-	"else":   ELSE,
-	"for":    FOR,
-	"return": RETURN,
+	"let":           LET,
+	"struct":        STRUCT,
+	"interface":     INTERFACE,
+	"type":          TYPE,
+	"newtype":       NEWTYPE,
+	"true":          TRUE,
+	"false":         FALSE,
+	"none":          NONE,
+	"func":          FUNC,
+	"extern":        EXTERN,
+	"if":            IF,
+	"else":          ELSE,
+	"for":           FOR,
+	"in":            IN,
+	"return":        RETURN,
+	"defer":         DEFER,
+	"static_assert": STATIC_ASSERT,
+	"assert":        ASSERT,
 }
 
 func (tokenType TokenType) String() string {
@@ -138,10 +170,18 @@ func (tokenType TokenType) String() string {
 		return "number"
 	case STRING:
 		return "string"
+	case STRING_HEAD:
+		return "string_head"
+	case STRING_MIDDLE:
+		return "string_middle"
+	case STRING_TAIL:
+		return "string_tail"
 	case TRUE:
 		return "true"
 	case FALSE:
 		return "false"
+	case NONE:
+		return "none"
 	case IDENTIFIER:
 		return "identifier"
 	case OPEN_BRACKET:
@@ -176,8 +216,20 @@ func (tokenType TokenType) String() string {
 		return "or"
 	case AND:
 		return "and"
+	case AT:
+		return "at"
 	case DOT:
 		return "dot"
+	case DOT_DOT:
+		return "dot_dot"
+	case ELLIPSIS:
+		return "ellipsis"
+	case QUESTION:
+		return "question"
+	case QUESTION_DOT:
+		return "question_dot"
+	case QUESTION_QUESTION:
+		return "question_question"
 	case SEMI_COLON:
 		return "semi_colon"
 	case COLON:
@@ -202,16 +254,32 @@ func (tokenType TokenType) String() string {
 		return "let"
 	case FUNC:
 		return "func"
+	case EXTERN:
+		return "extern"
 	case IF:
 		return "if"
 	case ELSE:
 		return "else"
 	case FOR:
 		return "for"
+	case IN:
+		return "in"
 	case STRUCT:
 		return "struct"
+	case INTERFACE:
+		return "interface"
+	case TYPE:
+		return "type"
+	case NEWTYPE:
+		return "newtype"
 	case RETURN:
 		return "return"
+	case DEFER:
+		return "defer"
+	case STATIC_ASSERT:
+		return "static_assert"
+	case ASSERT:
+		return "assert"
 	default:
 		return fmt.Sprintf("unknown(%d)", tokenType)
 	}
@@ -253,16 +321,88 @@ func tryMatchPattern(src string, re *regexp.Regexp, tokenType TokenType) (int, T
 	}
 }
 
+// Scans string literal text starting right after an opening quote or the closing
+// curly of an embedded expression, up to and including the closing quote or the
+// opening "${" of the next embedded expression.
+func scanStringSegment(src string, isHead bool) (int, Token) {
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '"':
+			if isHead {
+				return i + 1, Token{Type: STRING, Value: "\"" + src[:i+1]}
+			}
+			return i + 1, Token{Type: STRING_TAIL, Value: src[:i]}
+		case src[i] == '$' && i+1 < len(src) && src[i+1] == '{':
+			if isHead {
+				return i + 2, Token{Type: STRING_HEAD, Value: src[:i]}
+			}
+			return i + 2, Token{Type: STRING_MIDDLE, Value: src[:i]}
+		}
+	}
+	panic("Unterminated string literal")
+}
+
+type Span struct {
+	Start int
+	End   int
+}
+
 func Tokenize(src string) []Token {
+	tokens, _ := TokenizeWithSpans(src)
+	return tokens
+}
+
+// Like Tokenize, but also returns the byte range of each token in src.
+func TokenizeWithSpans(src string) ([]Token, []Span) {
+	return tokenize(src, false)
+}
+
+// Like TokenizeWithSpans, but keeps whitespace and comments as tokens too.
+func TokenizeWithTrivia(src string) ([]Token, []Span) {
+	return tokenize(src, true)
+}
+
+func tokenize(src string, keepTrivia bool) ([]Token, []Span) {
 	pos := 0
 	tokens := make([]Token, 0)
+	spans := make([]Span, 0)
+	// One entry per embedded expression being tokenized, counting the curly braces
+	// opened inside it so that its closing curly can be told apart from theirs.
+	interpolations := []int{}
 
 	for pos < len(src) {
 		remainingSrc := src[pos:]
+		depth := len(interpolations) - 1
+		switch {
+		case remainingSrc[0] == '"':
+			length, newToken := scanStringSegment(remainingSrc[1:], true)
+			tokens = append(tokens, newToken)
+			spans = append(spans, Span{pos, pos + length + 1})
+			pos += length + 1
+			if newToken.Type == STRING_HEAD {
+				interpolations = append(interpolations, 0)
+			}
+			continue
+		case remainingSrc[0] == '{' && depth >= 0:
+			interpolations[depth]++
+		case remainingSrc[0] == '}' && depth >= 0:
+			if interpolations[depth] == 0 {
+				length, newToken := scanStringSegment(remainingSrc[1:], false)
+				tokens = append(tokens, newToken)
+				spans = append(spans, Span{pos, pos + length + 1})
+				pos += length + 1
+				if newToken.Type == STRING_TAIL {
+					interpolations = interpolations[:depth]
+				}
+				continue
+			}
+			interpolations[depth]--
+		}
 		for _, tp := range tokenPatterns {
 			if length, newToken := tryMatchPattern(remainingSrc, tp.pattern, tp.tokenType); length != 0 {
-				if newToken.Type != WHITESPACE && newToken.Type != COMMENT {
+				if keepTrivia || (newToken.Type != WHITESPACE && newToken.Type != COMMENT) {
 					tokens = append(tokens, newToken)
+					spans = append(spans, Span{pos, pos + length})
 				}
 				pos += length
 				break
@@ -270,5 +410,5 @@ func Tokenize(src string) []Token {
 		}
 	}
 
-	return tokens
+	return tokens, spans
 }
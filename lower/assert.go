@@ -0,0 +1,149 @@
+package lower
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+)
+
+// The name of the function that aborts the program with a message. Like
+// returnValueName, it cannot clash with user code. Asserts declares it as an
+// extern function bound to trapSymbol, which back ends provide.
+const (
+	trapFuncName = "$trap"
+	trapSymbol   = "jru_trap"
+)
+
+// Asserts removes every StaticAssertStmt, which the type checker has already
+// evaluated, and turns every AssertStmt into an if statement that calls the
+// trap function with the source location of the assertion when its condition
+// does not hold. The location is only known for programs parsed with
+// parser.ParseWithSpans; otherwise the message names just the file. If any
+// assertion was lowered, the trap function is declared at the start of the
+// program, so the result type checks like the input.
+func Asserts(program ast.BlockStmt, filename string) ast.BlockStmt {
+	a := &assertLowerer{filename: filename}
+	lowered := a.lowerBlock(program)
+	if a.trapped {
+		lowered.Body = append([]ast.Stmt{trapFuncDecl()}, lowered.Body...)
+	}
+	return lowered
+}
+
+type assertLowerer struct {
+	filename string
+	trapped  bool
+}
+
+func trapFuncDecl() ast.FuncDeclStmt {
+	return ast.FuncDeclStmt{
+		Attributes: []ast.Attribute{
+			{
+				Name: "extern",
+				Args: []ast.Expr{
+					ast.StringLiteralExpr{
+						Value: fmt.Sprintf("\"%s\"", trapSymbol),
+					},
+				},
+			},
+		},
+		Extern: true,
+		Name:   trapFuncName,
+		Parameters: []ast.Parameter{
+			{
+				Name: "message",
+				Type: ast.NamedType{TypeName: "string"},
+			},
+		},
+		ReturnType: ast.NamedType{TypeName: "void"},
+	}
+}
+
+func (a *assertLowerer) lowerBlock(block ast.BlockStmt) ast.BlockStmt {
+	body := []ast.Stmt{}
+	for _, stmt := range block.Body {
+		if _, ok := stmt.(ast.StaticAssertStmt); ok {
+			continue
+		}
+		body = append(body, a.lowerStmt(stmt))
+	}
+	return ast.BlockStmt{
+		Body: body,
+	}
+}
+
+func (a *assertLowerer) lowerStmt(stmt ast.Stmt) ast.Stmt {
+	switch s := stmt.(type) {
+	case ast.BlockStmt:
+		return a.lowerBlock(s)
+	case ast.FuncDeclStmt:
+		s.Body = a.lowerBlock(s.Body)
+		return s
+	case ast.StructDeclStmt:
+		methods := make([]ast.FuncDeclStmt, len(s.Methods))
+		for i, method := range s.Methods {
+			method.Body = a.lowerBlock(method.Body)
+			methods[i] = method
+		}
+		nested := make([]ast.StructDeclStmt, len(s.NestedStructs))
+		for i, nestedStruct := range s.NestedStructs {
+			nested[i] = a.lowerStmt(nestedStruct).(ast.StructDeclStmt)
+		}
+		s.Methods = methods
+		s.NestedStructs = nested
+		return s
+	case ast.IfStmt:
+		s.Then = a.lowerStmt(s.Then)
+		if s.Else != nil {
+			s.Else = a.lowerStmt(s.Else)
+		}
+		return s
+	case ast.ForStmt:
+		s.Body = a.lowerBlock(s.Body)
+		return s
+	case ast.ForEachStmt:
+		s.Body = a.lowerBlock(s.Body)
+		return s
+	case ast.StaticAssertStmt:
+		return ast.BlockStmt{}
+	case ast.AssertStmt:
+		return a.lowerAssertStmt(s)
+	default:
+		return stmt
+	}
+}
+
+func (a *assertLowerer) lowerAssertStmt(stmt ast.AssertStmt) ast.IfStmt {
+	a.trapped = true
+	location := a.filename
+	if stmt.Location.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", a.filename, stmt.Location.Line, stmt.Location.Column)
+	}
+	return ast.IfStmt{
+		Cond: ast.UnaryExpr{
+			Operator: lexer.Token{
+				Type:  lexer.NOT,
+				Value: "!",
+			},
+			Rhs: ast.GroupExpr{
+				Expr: stmt.Cond,
+			},
+		},
+		Then: ast.BlockStmt{
+			Body: []ast.Stmt{
+				ast.ExpressionStmt{
+					Expr: ast.FuncCallExpr{
+						Func: ast.IdentExpr{
+							Value: trapFuncName,
+						},
+						Args: []ast.Expr{
+							ast.StringLiteralExpr{
+								Value: fmt.Sprintf("\"%s: assertion failed\"", location),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
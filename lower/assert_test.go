@@ -0,0 +1,42 @@
+package lower
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"github.com/ruistola/compiler-proto/parser"
+	"github.com/ruistola/compiler-proto/typechecker"
+	"testing"
+)
+
+func TestAsserts(t *testing.T) {
+	src := "static_assert(1 < 2, \"top level\");\n\nfunc main(): void {\n    let n: i32 = 3;\n    assert(n > 0 && n < 10);\n}\n"
+	tokens, spans := lexer.TokenizeWithSpans(src)
+	program := Asserts(parser.ParseWithSpans(src, tokens, spans), "test.jru")
+
+	if len(program.Body) != 2 {
+		t.Fatalf("expected the trap declaration and main, found %d statements", len(program.Body))
+	}
+	trap, ok := program.Body[0].(ast.FuncDeclStmt)
+	if !ok || trap.Name != trapFuncName || !trap.Extern {
+		t.Fatalf("expected an extern declaration of %s, found %s", trapFuncName, render(program.Body[0]))
+	}
+	want := `func main() { let n: i32 = 3; if (!(n > 0 && n < 10)) { $trap("test.jru:5:5: assertion failed"); } }`
+	if got := render(program.Body[1]); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	result := typechecker.Check(program)
+	if len(result.Errors) > 0 {
+		t.Fatalf("lowered program does not type check: %q", result.Errors)
+	}
+	if len(result.Externs) != 1 || result.Externs[0].Symbol != trapSymbol {
+		t.Fatalf("expected the trap function to be bound to %s, found %+v", trapSymbol, result.Externs)
+	}
+}
+
+func TestAssertsWithoutAssertions(t *testing.T) {
+	program := Asserts(parser.Parse(lexer.Tokenize("static_assert(true, \"x\");\nfunc main(): void {\n}\n")), "test.jru")
+	if got := render(program); got != "{ func main() {  } }" {
+		t.Fatalf("unexpected program %s", got)
+	}
+}
@@ -0,0 +1,571 @@
+// Package lower rewrites type checked ASTs into simpler equivalent ASTs that
+// back ends can translate without knowing about the higher level constructs.
+package lower
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"github.com/ruistola/compiler-proto/typechecker"
+	"reflect"
+)
+
+// The name of the temporary that holds a return value while deferred calls
+// run. It cannot be produced by the lexer, so it never shadows user code.
+const returnValueName = "$ret"
+
+// Defers removes every DeferStmt from the program. A deferred call runs when
+// the block containing the defer statement exits, in reverse order of the
+// defer statements reached in that block. A return statement exits every
+// enclosing block of the function, innermost first, after its value has been
+// evaluated. The arguments of a deferred call, and the receiver of a deferred
+// method call, are evaluated into temporaries when the defer statement is
+// reached, so later assignments and shadowing declarations do not affect them.
+// A defer statement used as the unbraced branch of an if statement is scoped
+// to that branch, as if it had been written in braces.
+//
+// The return value temporary has the return type of its function, and each
+// argument temporary has the type of the parameter the argument binds to, so
+// arguments like none or ok(v) type check as they did in the call. Parameters
+// are found by the name of the called function or method among the functions
+// and methods declared in the program. When the candidates disagree on the
+// type, the temporary is left for the type checker to infer, as is the
+// receiver temporary.
+//
+// A try expression returns from the function when its operand is an error, so
+// in a block with deferred calls it becomes an explicit check that returns
+// the error like a return statement does. The operand is evaluated into a
+// temporary before the statement containing the try expression, and the try
+// expression is replaced by the value of the temporary. Try expressions that
+// are evaluated conditionally or repeatedly, on the right of && and ||, in
+// the branches of an if expression or in the condition and iteration of a
+// for loop, are left as they are. The type checker reads r?.m as a try
+// expression followed by member access when r is a result, and so does this
+// pass when r is a call of a function declared to return a result.
+func Defers(program ast.BlockStmt) ast.BlockStmt {
+	d := &deferLowerer{
+		funcs:   map[string][]ast.FuncDeclStmt{},
+		methods: map[string][]ast.FuncDeclStmt{},
+	}
+	d.declareBlock(program)
+	return d.lowerBlock(program, nil)
+}
+
+type deferLowerer struct {
+	temps      int
+	tries      int
+	returnType ast.Type
+	funcs      map[string][]ast.FuncDeclStmt
+	methods    map[string][]ast.FuncDeclStmt
+}
+
+func (d *deferLowerer) declareBlock(block ast.BlockStmt) {
+	for _, stmt := range block.Body {
+		switch s := stmt.(type) {
+		case ast.BlockStmt:
+			d.declareBlock(s)
+		case ast.FuncDeclStmt:
+			d.funcs[s.Name] = append(d.funcs[s.Name], s)
+			d.declareBlock(s.Body)
+		case ast.StructDeclStmt:
+			d.declareStruct(s)
+		}
+	}
+}
+
+func (d *deferLowerer) declareStruct(stmt ast.StructDeclStmt) {
+	for _, method := range stmt.Methods {
+		d.methods[method.Name] = append(d.methods[method.Name], method)
+		d.declareBlock(method.Body)
+	}
+	for _, nestedStruct := range stmt.NestedStructs {
+		d.declareStruct(nestedStruct)
+	}
+}
+
+func (d *deferLowerer) lowerBlock(block ast.BlockStmt, enclosing [][]ast.Expr) ast.BlockStmt {
+	deferred := []ast.Expr{}
+	body := []ast.Stmt{}
+	returned := false
+	for _, stmt := range block.Body {
+		if s, ok := stmt.(ast.DeferStmt); ok {
+			temps, call := d.captureDeferred(s.Expr)
+			body = append(body, temps...)
+			deferred = append(deferred, call)
+			continue
+		}
+		scopes := append(enclosing[:len(enclosing):len(enclosing)], deferred)
+		body = append(body, d.lowerStmt(stmt, scopes)...)
+		if alwaysReturns(stmt) {
+			returned = true
+		}
+	}
+	if !returned {
+		body = append(body, deferredCalls([][]ast.Expr{deferred})...)
+	}
+	return ast.BlockStmt{
+		Body: body,
+	}
+}
+
+// Evaluates the arguments of a deferred call, and the receiver of a deferred
+// method call, into fresh temporaries. Returns the declarations of the
+// temporaries and the call rewritten to use them.
+func (d *deferLowerer) captureDeferred(expr ast.Expr) ([]ast.Stmt, ast.Expr) {
+	call, ok := expr.(ast.FuncCallExpr)
+	if !ok {
+		return nil, expr
+	}
+	temps := []ast.Stmt{}
+	candidates := d.callees(call.Func)
+	if member, ok := call.Func.(ast.StructMemberExpr); ok {
+		temp, ident := d.temp(member.Struct, nil)
+		temps = append(temps, temp)
+		member.Struct = ident
+		call.Func = member
+	}
+	args := make([]ast.Expr, len(call.Args))
+	for i, arg := range call.Args {
+		argType := boundParamType(candidates, i, arg)
+		switch a := arg.(type) {
+		case ast.NamedArgExpr:
+			temp, ident := d.temp(a.Value, argType)
+			temps = append(temps, temp)
+			a.Value = ident
+			args[i] = a
+		case ast.SpreadExpr:
+			temp, ident := d.temp(a.Expr, argType)
+			temps = append(temps, temp)
+			a.Expr = ident
+			args[i] = a
+		default:
+			temp, ident := d.temp(arg, argType)
+			temps = append(temps, temp)
+			args[i] = ident
+		}
+	}
+	call.Args = args
+	return temps, call
+}
+
+// Returns the declarations of the functions or methods that a call of callee
+// may resolve to.
+func (d *deferLowerer) callees(callee ast.Expr) []ast.FuncDeclStmt {
+	switch c := callee.(type) {
+	case ast.IdentExpr:
+		return d.funcs[c.Value]
+	case ast.StructMemberExpr:
+		return d.methods[c.Member.Value]
+	}
+	return nil
+}
+
+// Reports whether expr is a call of a function or method declared to return
+// a result.
+func (d *deferLowerer) returnsResult(expr ast.Expr) bool {
+	call, ok := expr.(ast.FuncCallExpr)
+	if !ok {
+		return false
+	}
+	candidates := d.callees(call.Func)
+	for _, candidate := range candidates {
+		if _, ok := candidate.ReturnType.(ast.ResultType); !ok {
+			return false
+		}
+	}
+	return len(candidates) > 0
+}
+
+// Returns the type of the parameter that the argument at index i of a call
+// binds to, or nil unless every candidate agrees on one. A spread argument
+// binds to an array of the variadic element type.
+func boundParamType(candidates []ast.FuncDeclStmt, i int, arg ast.Expr) ast.Type {
+	var found ast.Type
+	for _, candidate := range candidates {
+		paramType := paramTypeAt(candidate.Parameters, i, arg)
+		if paramType == nil || (found != nil && !reflect.DeepEqual(found, paramType)) {
+			return nil
+		}
+		found = paramType
+	}
+	return found
+}
+
+func paramTypeAt(params []ast.Parameter, i int, arg ast.Expr) ast.Type {
+	var variadic *ast.Parameter
+	if len(params) > 0 && params[len(params)-1].Variadic {
+		variadic = &params[len(params)-1]
+	}
+	switch a := arg.(type) {
+	case ast.NamedArgExpr:
+		for _, param := range params {
+			if param.Name == a.Name && !param.Variadic {
+				return param.Type
+			}
+		}
+		return nil
+	case ast.SpreadExpr:
+		if variadic == nil {
+			return nil
+		}
+		return ast.ArrayType{
+			UnderlyingType: variadic.Type,
+		}
+	}
+	if i < len(params) {
+		return params[i].Type
+	}
+	if variadic != nil {
+		return variadic.Type
+	}
+	return nil
+}
+
+func (d *deferLowerer) temp(value ast.Expr, valueType ast.Type) (ast.Stmt, ast.IdentExpr) {
+	ident := ast.IdentExpr{
+		Value: fmt.Sprintf("$defer%d", d.temps),
+	}
+	d.temps++
+	return ast.VarDeclStmt{
+		Var: ast.TypedIdent{
+			Name: ident.Value,
+			Type: valueType,
+		},
+		InitVal: value,
+	}, ident
+}
+
+// Reports whether control never continues past stmt: it is a return, a block
+// that always reaches one, or an if statement whose branches all return.
+func alwaysReturns(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case ast.ReturnStmt:
+		return true
+	case ast.BlockStmt:
+		for _, inner := range s.Body {
+			if alwaysReturns(inner) {
+				return true
+			}
+		}
+	case ast.IfStmt:
+		return s.Else != nil && alwaysReturns(s.Then) && alwaysReturns(s.Else)
+	}
+	return false
+}
+
+func (d *deferLowerer) lowerStmt(stmt ast.Stmt, scopes [][]ast.Expr) []ast.Stmt {
+	if len(deferredCalls(scopes)) > 0 {
+		t := &tryLowerer{
+			d:      d,
+			scopes: scopes,
+		}
+		stmt = t.lowerStmt(stmt)
+		if len(t.checks) > 0 {
+			if stmt == nil {
+				return t.checks
+			}
+			return append(t.checks, d.lowerStmt(stmt, scopes)...)
+		}
+	}
+	switch s := stmt.(type) {
+	case ast.BlockStmt:
+		return []ast.Stmt{d.lowerBlock(s, scopes)}
+	case ast.FuncDeclStmt:
+		return []ast.Stmt{d.lowerFuncDeclStmt(s)}
+	case ast.StructDeclStmt:
+		return []ast.Stmt{d.lowerStructDeclStmt(s)}
+	case ast.IfStmt:
+		s.Then = d.lowerNestedStmt(s.Then, scopes)
+		if s.Else != nil {
+			s.Else = d.lowerNestedStmt(s.Else, scopes)
+		}
+		return []ast.Stmt{s}
+	case ast.ForStmt:
+		s.Body = d.lowerBlock(s.Body, scopes)
+		return []ast.Stmt{s}
+	case ast.ForEachStmt:
+		s.Body = d.lowerBlock(s.Body, scopes)
+		return []ast.Stmt{s}
+	case ast.ReturnStmt:
+		return d.lowerReturnStmt(s, scopes)
+	default:
+		return []ast.Stmt{stmt}
+	}
+}
+
+func (d *deferLowerer) lowerFuncDeclStmt(stmt ast.FuncDeclStmt) ast.FuncDeclStmt {
+	outerReturnType := d.returnType
+	d.returnType = stmt.ReturnType
+	stmt.Body = d.lowerBlock(stmt.Body, nil)
+	d.returnType = outerReturnType
+	return stmt
+}
+
+func (d *deferLowerer) lowerStructDeclStmt(stmt ast.StructDeclStmt) ast.StructDeclStmt {
+	methods := make([]ast.FuncDeclStmt, len(stmt.Methods))
+	for i, method := range stmt.Methods {
+		methods[i] = d.lowerFuncDeclStmt(method)
+	}
+	nested := make([]ast.StructDeclStmt, len(stmt.NestedStructs))
+	for i, nestedStruct := range stmt.NestedStructs {
+		nested[i] = d.lowerStructDeclStmt(nestedStruct)
+	}
+	stmt.Methods = methods
+	stmt.NestedStructs = nested
+	return stmt
+}
+
+func (d *deferLowerer) lowerNestedStmt(stmt ast.Stmt, scopes [][]ast.Expr) ast.Stmt {
+	if block, ok := stmt.(ast.BlockStmt); ok {
+		return d.lowerBlock(block, scopes)
+	}
+	if _, ok := stmt.(ast.DeferStmt); ok {
+		return d.lowerBlock(ast.BlockStmt{
+			Body: []ast.Stmt{stmt},
+		}, scopes)
+	}
+	lowered := d.lowerStmt(stmt, scopes)
+	if len(lowered) == 1 {
+		return lowered[0]
+	}
+	return ast.BlockStmt{
+		Body: lowered,
+	}
+}
+
+func (d *deferLowerer) lowerReturnStmt(stmt ast.ReturnStmt, scopes [][]ast.Expr) []ast.Stmt {
+	calls := deferredCalls(scopes)
+	if len(calls) == 0 {
+		return []ast.Stmt{stmt}
+	}
+	if stmt.Expr == nil {
+		return append(calls, stmt)
+	}
+	body := []ast.Stmt{
+		ast.VarDeclStmt{
+			Var: ast.TypedIdent{
+				Name: returnValueName,
+				Type: d.returnType,
+			},
+			InitVal: stmt.Expr,
+		},
+	}
+	body = append(body, calls...)
+	return append(body, ast.ReturnStmt{
+		Expr: ast.IdentExpr{
+			Value: returnValueName,
+		},
+	})
+}
+
+func deferredCalls(scopes [][]ast.Expr) []ast.Stmt {
+	calls := []ast.Stmt{}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for j := len(scopes[i]) - 1; j >= 0; j-- {
+			calls = append(calls, ast.ExpressionStmt{
+				Expr: scopes[i][j],
+			})
+		}
+	}
+	return calls
+}
+
+// Rewrites the try expressions of a statement into checks of temporaries that
+// run before it.
+type tryLowerer struct {
+	d      *deferLowerer
+	scopes [][]ast.Expr
+	checks []ast.Stmt
+}
+
+// Returns stmt with its try expressions replaced, or nil when the statement
+// was a try expression whose value is unused.
+func (t *tryLowerer) lowerStmt(stmt ast.Stmt) ast.Stmt {
+	switch s := stmt.(type) {
+	case ast.ExpressionStmt:
+		if try, ok := s.Expr.(ast.TryExpr); ok {
+			t.lowerTryExpr(try)
+			return nil
+		}
+		s.Expr = t.lowerExpr(s.Expr)
+		return s
+	case ast.VarDeclStmt:
+		if s.InitVal != nil {
+			s.InitVal = t.lowerExpr(s.InitVal)
+		}
+		return s
+	case ast.TupleDeclStmt:
+		s.InitVal = t.lowerExpr(s.InitVal)
+		return s
+	case ast.ReturnStmt:
+		if s.Expr != nil {
+			s.Expr = t.lowerExpr(s.Expr)
+		}
+		return s
+	case ast.IfStmt:
+		s.Cond = t.lowerExpr(s.Cond)
+		return s
+	case ast.ForStmt:
+		if s.Init != nil {
+			s.Init = t.lowerStmt(s.Init)
+		}
+		return s
+	case ast.ForEachStmt:
+		s.Iterable = t.lowerExpr(s.Iterable)
+		return s
+	case ast.AssertStmt:
+		s.Cond = t.lowerExpr(s.Cond)
+		return s
+	default:
+		return stmt
+	}
+}
+
+func (t *tryLowerer) lowerExprs(exprs []ast.Expr) []ast.Expr {
+	lowered := make([]ast.Expr, len(exprs))
+	for i, expr := range exprs {
+		lowered[i] = t.lowerExpr(expr)
+	}
+	return lowered
+}
+
+func (t *tryLowerer) lowerExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case ast.TryExpr:
+		return t.lowerTryExpr(e)
+	case ast.UnaryExpr:
+		e.Rhs = t.lowerExpr(e.Rhs)
+		return e
+	case ast.BinaryExpr:
+		e.Lhs = t.lowerExpr(e.Lhs)
+		if e.Operator.Type != lexer.AND && e.Operator.Type != lexer.OR {
+			e.Rhs = t.lowerExpr(e.Rhs)
+		}
+		return e
+	case ast.GroupExpr:
+		e.Expr = t.lowerExpr(e.Expr)
+		return e
+	case ast.TupleExpr:
+		e.Elems = t.lowerExprs(e.Elems)
+		return e
+	case ast.InterpolatedStringExpr:
+		e.Exprs = t.lowerExprs(e.Exprs)
+		return e
+	case ast.FuncCallExpr:
+		e.Func = t.lowerExpr(e.Func)
+		e.Args = t.lowerExprs(e.Args)
+		return e
+	case ast.SpreadExpr:
+		e.Expr = t.lowerExpr(e.Expr)
+		return e
+	case ast.NamedArgExpr:
+		e.Value = t.lowerExpr(e.Value)
+		return e
+	case ast.MapLiteralExpr:
+		entries := make([]ast.MapEntry, len(e.Entries))
+		for i, entry := range e.Entries {
+			entries[i] = ast.MapEntry{
+				Key:   t.lowerExpr(entry.Key),
+				Value: t.lowerExpr(entry.Value),
+			}
+		}
+		e.Entries = entries
+		return e
+	case ast.StructLiteralExpr:
+		if e.Base != nil {
+			e.Base = t.lowerExpr(e.Base)
+		}
+		members := make([]ast.MemberAssignExpr, len(e.Members))
+		for i, member := range e.Members {
+			member.Value = t.lowerExpr(member.Value)
+			members[i] = member
+		}
+		e.Members = members
+		return e
+	case ast.StructMemberExpr:
+		e.Struct = t.lowerExpr(e.Struct)
+		return e
+	case ast.OptionalMemberExpr:
+		if t.d.returnsResult(e.Struct) {
+			return ast.StructMemberExpr{
+				Struct: t.lowerTryExpr(ast.TryExpr{
+					Expr: e.Struct,
+				}),
+				Member: e.Member,
+			}
+		}
+		e.Struct = t.lowerExpr(e.Struct)
+		return e
+	case ast.ArrayIndexExpr:
+		e.Array = t.lowerExpr(e.Array)
+		e.Index = t.lowerExpr(e.Index)
+		return e
+	case ast.SliceExpr:
+		e.Array = t.lowerExpr(e.Array)
+		if e.Start != nil {
+			e.Start = t.lowerExpr(e.Start)
+		}
+		if e.End != nil {
+			e.End = t.lowerExpr(e.End)
+		}
+		return e
+	case ast.IfExpr:
+		e.Cond = t.lowerExpr(e.Cond)
+		return e
+	case ast.RangeExpr:
+		e.Start = t.lowerExpr(e.Start)
+		e.End = t.lowerExpr(e.End)
+		return e
+	case ast.AssignExpr:
+		e.Assigne = t.lowerExpr(e.Assigne)
+		e.AssignedValue = t.lowerExpr(e.AssignedValue)
+		return e
+	default:
+		return expr
+	}
+}
+
+// Evaluates the operand of a try expression into a temporary, returns its
+// error through the deferred calls when it is one, and returns the expression
+// that reads its value.
+func (t *tryLowerer) lowerTryExpr(expr ast.TryExpr) ast.Expr {
+	operand := t.lowerExpr(expr.Expr)
+	ident := ast.IdentExpr{
+		Value: fmt.Sprintf("$try%d", t.d.tries),
+	}
+	t.d.tries++
+	errReturn := ast.ReturnStmt{
+		Expr: ast.FuncCallExpr{
+			Func: ast.IdentExpr{
+				Value: "err",
+			},
+			Args: []ast.Expr{
+				resultMember(ident, typechecker.ResultErrMember),
+			},
+		},
+	}
+	t.checks = append(t.checks,
+		ast.VarDeclStmt{
+			Var: ast.TypedIdent{
+				Name: ident.Value,
+			},
+			InitVal: operand,
+		},
+		ast.IfStmt{
+			Cond: resultMember(ident, typechecker.ResultIsErrMember),
+			Then: ast.BlockStmt{
+				Body: t.d.lowerReturnStmt(errReturn, t.scopes),
+			},
+		},
+	)
+	return resultMember(ident, typechecker.ResultValueMember)
+}
+
+func resultMember(result ast.IdentExpr, member string) ast.StructMemberExpr {
+	return ast.StructMemberExpr{
+		Struct: result,
+		Member: ast.IdentExpr{
+			Value: member,
+		},
+	}
+}
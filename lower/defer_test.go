@@ -0,0 +1,210 @@
+package lower
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"github.com/ruistola/compiler-proto/parser"
+	"github.com/ruistola/compiler-proto/typechecker"
+	"strings"
+	"testing"
+)
+
+// Renders the subset of the AST the lowering tests produce as compact source,
+// one statement after another.
+func render(node any) string {
+	switch n := node.(type) {
+	case ast.BlockStmt:
+		stmts := make([]string, len(n.Body))
+		for i, stmt := range n.Body {
+			stmts[i] = render(stmt)
+		}
+		return "{ " + strings.Join(stmts, " ") + " }"
+	case ast.FuncDeclStmt:
+		return fmt.Sprintf("func %s() %s", n.Name, render(n.Body))
+	case ast.VarDeclStmt:
+		if n.Var.Type == nil {
+			return fmt.Sprintf("let %s = %s;", n.Var.Name, render(n.InitVal))
+		}
+		return fmt.Sprintf("let %s: %s = %s;", n.Var.Name, render(n.Var.Type), render(n.InitVal))
+	case ast.ExpressionStmt:
+		return render(n.Expr) + ";"
+	case ast.ReturnStmt:
+		if n.Expr == nil {
+			return "return;"
+		}
+		return fmt.Sprintf("return %s;", render(n.Expr))
+	case ast.IfStmt:
+		if n.Else == nil {
+			return fmt.Sprintf("if (%s) %s", render(n.Cond), render(n.Then))
+		}
+		return fmt.Sprintf("if (%s) %s else %s", render(n.Cond), render(n.Then), render(n.Else))
+	case ast.NamedType:
+		return n.TypeName
+	case ast.ArrayType:
+		return render(n.UnderlyingType) + "[]"
+	case ast.OptionalType:
+		return render(n.UnderlyingType) + "?"
+	case ast.ResultType:
+		return fmt.Sprintf("Result<%s, %s>", render(n.ValueType), render(n.ErrType))
+	case ast.NoneLiteralExpr:
+		return "none"
+	case ast.IdentExpr:
+		return n.Value
+	case ast.NumberLiteralExpr:
+		return n.Value
+	case ast.StringLiteralExpr:
+		return n.Value
+	case ast.GroupExpr:
+		return fmt.Sprintf("(%s)", render(n.Expr))
+	case ast.UnaryExpr:
+		return n.Operator.Value + render(n.Rhs)
+	case ast.BinaryExpr:
+		return fmt.Sprintf("%s %s %s", render(n.Lhs), n.Operator.Value, render(n.Rhs))
+	case ast.TryExpr:
+		return render(n.Expr) + "?"
+	case ast.StructMemberExpr:
+		return fmt.Sprintf("%s.%s", render(n.Struct), n.Member.Value)
+	case ast.SpreadExpr:
+		return render(n.Expr) + "..."
+	case ast.NamedArgExpr:
+		return fmt.Sprintf("%s: %s", n.Name, render(n.Value))
+	case ast.FuncCallExpr:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = render(arg)
+		}
+		return fmt.Sprintf("%s(%s)", render(n.Func), strings.Join(args, ", "))
+	default:
+		return fmt.Sprintf("<%T>", node)
+	}
+}
+
+func lowerDefers(src string) string {
+	program := Defers(parser.Parse(lexer.Tokenize(src)))
+	return render(program.Body[0])
+}
+
+func TestDefers(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"runs in LIFO order at block exit",
+			`func f(): void { defer a(1); defer b(2); c(); }`,
+			`func f() { let $defer0 = 1; let $defer1 = 2; c(); b($defer1); a($defer0); }`,
+		},
+		{
+			"nested blocks run their own defers first",
+			`func f(): void { defer a(); { defer b(); c(); } d(); }`,
+			`func f() { { c(); b(); } d(); a(); }`,
+		},
+		{
+			"early return runs every enclosing scope",
+			`func f(x: i32): i32 { defer a(x); if (x > 0) { defer b(); return x; } return 0; }`,
+			`func f() { let $defer0 = x; if (x > 0) { let $ret: i32 = x; b(); a($defer0); return $ret; } let $ret: i32 = 0; a($defer0); return $ret; }`,
+		},
+		{
+			"no unreachable calls after returning branches",
+			`func f(x: i32): i32 { defer a(); if (x > 0) { return 1; } else { return 2; } }`,
+			`func f() { if (x > 0) { let $ret: i32 = 1; a(); return $ret; } else { let $ret: i32 = 2; a(); return $ret; } }`,
+		},
+		{
+			"unbraced if branch is its own scope",
+			`func f(c: bool): void { if (c) defer a(); b(); }`,
+			`func f() { if (c) { a(); } b(); }`,
+		},
+		{
+			"temporaries have the types of the parameters they bind to",
+			`func f(xs: i32[]): void { defer log(none, count: 1); defer sum(1, 2); defer sum(xs...); } func log(x: i32?, count: i32): void {} func sum(args: ...i32): void {}`,
+			`func f() { let $defer0: i32? = none; let $defer1: i32 = 1; let $defer2: i32 = 1; let $defer3: i32 = 2; let $defer4: i32[] = xs; sum($defer4...); sum($defer2, $defer3); log($defer0, count: $defer1); }`,
+		},
+		{
+			"try expressions return through the deferred calls",
+			`func f(s: string): Result<i32, string> { defer a(); let x: i32 = parse(s)? + 1; check(x)?; return ok(x); }`,
+			`func f() { let $try0 = parse(s); if ($try0.$isErr) { let $ret: Result<i32, string> = err($try0.$err); a(); return $ret; } let x: i32 = $try0.$value + 1; let $try1 = check(x); if ($try1.$isErr) { let $ret: Result<i32, string> = err($try1.$err); a(); return $ret; } let $ret: Result<i32, string> = ok(x); a(); return $ret; }`,
+		},
+		{
+			"?. on a call returning a result is a try expression",
+			`func f(): Result<i32, string> { defer a(); return ok(get()?.x); } func get(): Result<P, string> {}`,
+			`func f() { let $try0 = get(); if ($try0.$isErr) { let $ret: Result<i32, string> = err($try0.$err); a(); return $ret; } let $ret: Result<i32, string> = ok($try0.$value.x); a(); return $ret; }`,
+		},
+		{
+			"try expressions without deferred calls are kept",
+			`func f(s: string): Result<i32, string> { let x: i32 = parse(s)?; { defer a(); } return ok(x); }`,
+			`func f() { let x: i32 = parse(s)?; { a(); } return ok(x); }`,
+		},
+		{
+			"arguments and receivers are captured at the defer",
+			`func f(x: i32): void { defer s.close(x); { let x: i32 = 2; return; } }`,
+			`func f() { let $defer0 = s; let $defer1 = x; { let x: i32 = 2; $defer0.close($defer1); return; } }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lowerDefers(tt.src); got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefersTypeCheck(t *testing.T) {
+	src := `
+struct File {
+    fd: i32,
+
+    func close(reason: string?): void {
+    }
+}
+
+func close(fd: i32?): void {
+}
+
+func parse(s: string): Result<i32, string> {
+    let v: i32 = 1;
+    defer close(none);
+    if (s == "") {
+        return err("empty");
+    }
+    return ok(v);
+}
+
+func sum(a: string, b: string): Result<i32, string> {
+    defer close(none);
+    let x: i32 = parse(a)?;
+    parse(b)?;
+    return ok(x + parse(b)?);
+}
+
+func open(name: string): Result<File, string> {
+    return ok(File{ fd: 3 });
+}
+
+func openFd(name: string): Result<i32, string> {
+    defer close(none);
+    return ok(open(name)?.fd);
+}
+
+func find(f: File): i32? {
+    defer f.close(none);
+    defer close(f.fd);
+    if (f.fd < 0) {
+        return none;
+    }
+    return f.fd;
+}
+
+func main(): void {
+}
+`
+	program := parser.Parse(lexer.Tokenize(src))
+	if result := typechecker.Check(program); len(result.Errors) > 0 {
+		t.Fatalf("program does not type check: %q", result.Errors)
+	}
+	if result := typechecker.Check(Defers(program)); len(result.Errors) > 0 {
+		t.Fatalf("lowered program does not type check: %q", result.Errors)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/ruistola/compiler-proto/lexer"
+	"github.com/ruistola/compiler-proto/lower"
 	"github.com/ruistola/compiler-proto/parser"
 	"github.com/ruistola/compiler-proto/typechecker"
 	"github.com/yassinebenaid/godump"
@@ -20,14 +21,14 @@ func main() {
 	totalDuration := time.Duration(0)
 
 	startTokenization := time.Now()
-	tokens := lexer.Tokenize(src)
+	tokens, spans := lexer.TokenizeWithSpans(src)
 	durationTokenization := time.Since(startTokenization)
 	totalDuration += durationTokenization
 	fmt.Printf("Tokenized %s in %v.\n\n", filename, durationTokenization)
 	fmt.Printf("Tokens:\n%s\n\n", tokens)
 
 	startParsing := time.Now()
-	ast := parser.Parse(tokens)
+	ast := parser.ParseWithSpans(src, tokens, spans)
 	durationParsing := time.Since(startParsing)
 	totalDuration += durationParsing
 	fmt.Printf("Parsed %s in %v.\n\n", filename, durationParsing)
@@ -36,17 +37,34 @@ func main() {
 	godump.Dump(ast)
 
 	startTypeChecking := time.Now()
-	errors := typechecker.Check(ast)
+	result := typechecker.Check(ast)
 	durationTypeChecking := time.Since(startTypeChecking)
 	totalDuration += durationTypeChecking
-	if len(errors) == 0 {
+	for _, warning := range result.Warnings {
+		fmt.Println(warning)
+	}
+	if len(result.Errors) == 0 {
 		fmt.Println("0 errors.")
 	} else {
-		for _, err := range errors {
+		for _, err := range result.Errors {
 			fmt.Println(err)
 		}
 	}
+	for _, extern := range result.Externs {
+		fmt.Printf("Extern %s: %s bound to symbol %s\n", extern.Name, extern.Type, extern.Symbol)
+	}
 	fmt.Printf("Type checked %s in %v.\n\n", filename, durationTypeChecking)
 
+	if len(result.Errors) == 0 {
+		startLowering := time.Now()
+		lowered := lower.Asserts(lower.Defers(ast), filename)
+		durationLowering := time.Since(startLowering)
+		totalDuration += durationLowering
+		fmt.Printf("Lowered %s in %v.\n\n", filename, durationLowering)
+
+		fmt.Println("Lowered AST:")
+		godump.Dump(lowered)
+	}
+
 	fmt.Printf("Done in %v.\n", totalDuration)
 }
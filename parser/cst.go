@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/cst"
+	"github.com/ruistola/compiler-proto/lexer"
+	"slices"
+	"strings"
+)
+
+// Collects the concrete syntax tree while parsing. Consumed tokens are
+// appended to a flat list of elements, and once a statement, expression or
+// type has been parsed, the elements it consumed since its checkpoint are
+// replaced by a single node. A nil builder does nothing.
+type cstBuilder struct {
+	tokens   []cst.Token
+	elements []cst.Element
+}
+
+func (b *cstBuilder) token(pos int) {
+	if b == nil || pos >= len(b.tokens) {
+		return
+	}
+	b.elements = append(b.elements, b.tokens[pos])
+}
+
+func (b *cstBuilder) checkpoint() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.elements)
+}
+
+func (b *cstBuilder) wrap(checkpoint int, astNode any) {
+	if b == nil {
+		return
+	}
+	node := cst.Node{
+		Kind:     strings.TrimPrefix(fmt.Sprintf("%T", astNode), "ast."),
+		Children: slices.Clone(b.elements[checkpoint:]),
+	}
+	b.elements = append(b.elements[:checkpoint], node)
+}
+
+func ParseCST(src string) cst.Node {
+	allTokens, spans := lexer.TokenizeWithTrivia(src)
+	builder := &cstBuilder{}
+	tokens := []lexer.Token{}
+	tokenSpans := []lexer.Span{}
+	trivia := []cst.Trivia{}
+	for i, token := range allTokens {
+		text := src[spans[i].Start:spans[i].End]
+		if token.Type == lexer.WHITESPACE || token.Type == lexer.COMMENT {
+			trivia = append(trivia, cst.Trivia{
+				Type: token.Type,
+				Text: text,
+				Span: spans[i],
+			})
+			continue
+		}
+		tokens = append(tokens, token)
+		tokenSpans = append(tokenSpans, spans[i])
+		builder.tokens = append(builder.tokens, cst.Token{
+			Type:          token.Type,
+			Text:          text,
+			Span:          spans[i],
+			LeadingTrivia: trivia,
+		})
+		trivia = []cst.Trivia{}
+	}
+
+	p := parser{tokens: tokens, operators: DefaultOperators, cst: builder, src: src, spans: tokenSpans}
+	p.parseProgram()
+	eof := cst.Token{
+		Type:          lexer.EOF,
+		Span:          lexer.Span{Start: len(src), End: len(src)},
+		LeadingTrivia: trivia,
+	}
+	return cst.Node{
+		Kind:     "Program",
+		Children: append(builder.elements, eof),
+	}
+}
+
+// LowerCST builds the abstract syntax tree of a program from the current
+// tokens of its concrete syntax tree, so edits made to the tree since it was
+// parsed are reflected in the result.
+func LowerCST(program cst.Node) ast.BlockStmt {
+	src := program.Source()
+	tokens, spans := lexer.TokenizeWithSpans(src)
+	return ParseWithSpans(src, tokens, spans)
+}
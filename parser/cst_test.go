@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/cst"
+	"github.com/ruistola/compiler-proto/lexer"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCST(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.jru")
+	if err != nil {
+		t.Fatalf("Failed to find source files : %v", err)
+	}
+	for _, filename := range files {
+		t.Run(filename, func(t *testing.T) {
+			sourceBytes, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read file %s : %v", filename, err)
+			}
+			src := string(sourceBytes)
+			tree := ParseCST(src)
+			if tree.Source() != src {
+				t.Fatalf("concrete syntax tree does not reproduce the source of %s", filename)
+			}
+			tokens, spans := lexer.TokenizeWithSpans(src)
+			if !reflect.DeepEqual(LowerCST(tree), ParseWithSpans(src, tokens, spans)) {
+				t.Fatalf("lowered concrete syntax tree differs from the parse of %s", filename)
+			}
+		})
+	}
+}
+
+func TestParseCSTKeepsPunctuation(t *testing.T) {
+	src := "func f(\n    a: i32, // first\n    b: i32,\n): i32 {\n    return (a + b);\n}\n"
+	tree := ParseCST(src)
+	funcDecl := tree.Nodes()[0]
+	if funcDecl.Kind != "FuncDeclStmt" {
+		t.Fatalf("expected FuncDeclStmt, found %s", funcDecl.Kind)
+	}
+	commas := 0
+	for _, token := range funcDecl.Tokens() {
+		if token.Type == lexer.COMMA {
+			commas++
+		}
+	}
+	if commas != 2 {
+		t.Fatalf("expected both parameter commas to be kept, found %d", commas)
+	}
+	returnStmt := funcDecl.Nodes()[len(funcDecl.Nodes())-1]
+	if returnStmt.Kind != "ReturnStmt" || returnStmt.Nodes()[0].Kind != "GroupExpr" {
+		t.Fatalf("expected a return of a group expression, found %s", returnStmt.Source())
+	}
+	if tree.Source() != src {
+		t.Fatalf("concrete syntax tree does not reproduce the source")
+	}
+}
+
+// Returns a copy of node with the text of every token of the given type and
+// text replaced.
+func replaceToken(node cst.Node, tokenType lexer.TokenType, text string, replacement string) cst.Node {
+	children := make([]cst.Element, len(node.Children))
+	for i, child := range node.Children {
+		switch c := child.(type) {
+		case cst.Token:
+			if c.Type == tokenType && c.Text == text {
+				c.Text = replacement
+			}
+			children[i] = c
+		case cst.Node:
+			children[i] = replaceToken(c, tokenType, text, replacement)
+		}
+	}
+	node.Children = children
+	return node
+}
+
+func TestLowerCSTReflectsEdits(t *testing.T) {
+	tree := ParseCST("func main(): void {\n    let x: i32 = 1;\n}\n")
+	edited := replaceToken(tree, lexer.NUMBER, "1", "42")
+	edited = replaceToken(edited, lexer.IDENTIFIER, "x", "answer")
+	want := ast.VarDeclStmt{
+		Var: ast.TypedIdent{
+			Name: "answer",
+			Type: ast.NamedType{TypeName: "i32"},
+		},
+		InitVal: ast.NumberLiteralExpr{Value: "42"},
+	}
+	funcDecl := LowerCST(edited).Body[0].(ast.FuncDeclStmt)
+	if got := funcDecl.Body.Body[0]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("lowered edited tree has %+v, want %+v", got, want)
+	}
+	original := LowerCST(tree).Body[0].(ast.FuncDeclStmt)
+	if got := original.Body.Body[0].(ast.VarDeclStmt).InitVal; !reflect.DeepEqual(got, ast.NumberLiteralExpr{Value: "1"}) {
+		t.Fatalf("editing a copy changed the original tree")
+	}
+}
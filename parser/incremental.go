@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"slices"
+	"strings"
+)
+
+// Document is a parsed program that remembers enough about its source to be
+// reparsed incrementally after an edit. The source locations in Program are
+// relative to the top level statement containing them, so that statements
+// reused after an edit stay correct; Location converts them to locations in
+// Source.
+type Document struct {
+	Source  string
+	Program ast.BlockStmt
+	decls   []declaration
+}
+
+// A top level statement together with the tokens it was parsed from. Token
+// spans are relative to start so that declarations after an edit can be
+// reused by only moving start and end.
+type declaration struct {
+	start  int
+	end    int
+	tokens []lexer.Token
+	spans  []lexer.Span
+}
+
+// Edit replaces the bytes in [Start, End) of the previous source with Text.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+func ParseDocument(src string) Document {
+	tokens, spans := lexer.TokenizeWithSpans(src)
+	stmts, decls := parseDeclarations(src, tokens, spans, 0)
+	return Document{
+		Source: src,
+		Program: ast.BlockStmt{
+			Body: stmts,
+		},
+		decls: decls,
+	}
+}
+
+// Reparse applies the edit to the source of doc and returns the resulting
+// document. Only the top level declarations touched by the edit are lexed and
+// parsed again; if lexing the edited text changes how a following
+// declaration is tokenized (e.g. a string literal left unterminated now runs
+// into it), that declaration is reparsed as well. The result is the same as
+// calling ParseDocument on the edited source.
+func Reparse(doc Document, edit Edit) Document {
+	src := doc.Source[:edit.Start] + edit.Text + doc.Source[edit.End:]
+	delta := len(edit.Text) - (edit.End - edit.Start)
+
+	// Declarations in [first, last) are parsed again, starting from the end of
+	// the declaration before first. They are the declarations touched by the
+	// edit, plus the one before them because the parser may have peeked at the
+	// edited text when deciding where that one ends (e.g. looking for an else
+	// after an if statement).
+	first := 0
+	for first < len(doc.decls) && doc.decls[first].end < edit.Start {
+		first++
+	}
+	last := first
+	for last < len(doc.decls) && doc.decls[last].start <= edit.End {
+		last++
+	}
+	if first > 0 {
+		first--
+	}
+	regionStart := 0
+	if first > 0 {
+		regionStart = doc.decls[first-1].end
+	}
+
+	var stmts []ast.Stmt
+	var decls []declaration
+	for {
+		regionEnd := len(src)
+		if last < len(doc.decls) {
+			regionEnd = doc.decls[last].end + delta
+		}
+		tokens, spans, ok := tokenizeRegion(src[regionStart:regionEnd], last < len(doc.decls))
+		if !ok || last < len(doc.decls) && !endsWithDeclaration(tokens, spans, regionEnd-regionStart, doc.decls[last]) {
+			last++
+			continue
+		}
+		stmts, decls = parseDeclarations(src[regionStart:regionEnd], tokens, spans, regionStart)
+		if last < len(doc.decls) {
+			last++
+		}
+		break
+	}
+
+	return Document{
+		Source: src,
+		Program: ast.BlockStmt{
+			Body: slices.Concat(doc.Program.Body[:first], stmts, doc.Program.Body[last:]),
+		},
+		decls: slices.Concat(doc.decls[:first], decls, shifted(doc.decls[last:], delta)),
+	}
+}
+
+// Tokenizes a region of the edited source. A string literal left unterminated
+// by the edit may end in a later declaration, so unless the region reaches the
+// end of the source, failing to lex it only means that it must be extended.
+func tokenizeRegion(src string, extendable bool) (tokens []lexer.Token, spans []lexer.Span, ok bool) {
+	if extendable {
+		defer func() {
+			if recover() != nil {
+				ok = false
+			}
+		}()
+	}
+	tokens, spans = lexer.TokenizeWithSpans(src)
+	return tokens, spans, true
+}
+
+func shifted(decls []declaration, delta int) []declaration {
+	result := make([]declaration, len(decls))
+	for i, decl := range decls {
+		decl.start += delta
+		decl.end += delta
+		result[i] = decl
+	}
+	return result
+}
+
+// Reports whether the relexed region ends with exactly the tokens of decl, at
+// the same offsets relative to the end of the region. If it does, the lexer
+// was back in sync before reaching decl and everything after it is unchanged.
+func endsWithDeclaration(tokens []lexer.Token, spans []lexer.Span, regionLen int, decl declaration) bool {
+	n := len(decl.tokens)
+	if len(tokens) < n {
+		return false
+	}
+	offset := regionLen - (decl.end - decl.start)
+	for i := range n {
+		j := len(tokens) - n + i
+		if tokens[j] != decl.tokens[i] || spans[j].Start != decl.spans[i].Start+offset || spans[j].End != decl.spans[i].End+offset {
+			return false
+		}
+	}
+	return true
+}
+
+// Location converts loc, recorded in the top level statement at index i of
+// the program, to a location in the source.
+func (doc Document) Location(i int, loc ast.Location) ast.Location {
+	if loc.Line == 0 {
+		return loc
+	}
+	before := doc.Source[:doc.decls[i].start]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	if loc.Line == 1 {
+		loc.Column += len(before) - lineStart
+	}
+	loc.Line += strings.Count(before, "\n")
+	return loc
+}
+
+func parseDeclarations(src string, tokens []lexer.Token, spans []lexer.Span, base int) ([]ast.Stmt, []declaration) {
+	p := parser{tokens: tokens, operators: DefaultOperators, src: src, spans: spans}
+	var stmts []ast.Stmt
+	var decls []declaration
+	for p.peek().Type != lexer.EOF {
+		from := p.pos
+		p.locationBase = spans[from].Start
+		stmts = append(stmts, p.parseStmt())
+		start := spans[from].Start
+		declSpans := make([]lexer.Span, p.pos-from)
+		for i, span := range spans[from:p.pos] {
+			declSpans[i] = lexer.Span{Start: span.Start - start, End: span.End - start}
+		}
+		decls = append(decls, declaration{
+			start:  base + start,
+			end:    base + spans[p.pos-1].End,
+			tokens: tokens[from:p.pos],
+			spans:  declSpans,
+		})
+	}
+	return stmts, decls
+}
@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func checkReparse(t *testing.T, doc Document, edit Edit) Document {
+	t.Helper()
+	got := Reparse(doc, edit)
+	want := ParseDocument(got.Source)
+	if !reflect.DeepEqual(got.Program, want.Program) {
+		t.Fatalf("reparse after %+v differs from full parse\ngot:  %+v\nwant: %+v", edit, got.Program, want.Program)
+	}
+	tokens, spans := lexer.TokenizeWithSpans(got.Source)
+	full := ParseWithSpans(got.Source, tokens, spans)
+	for i, stmt := range got.Program.Body {
+		locations := assertLocations(stmt)
+		for j, loc := range assertLocations(full.Body[i]) {
+			if got.Location(i, locations[j]) != loc {
+				t.Fatalf("reparse after %+v locates an assertion at %+v, want %+v", edit, got.Location(i, locations[j]), loc)
+			}
+		}
+	}
+	if !reflect.DeepEqual(got.decls, want.decls) {
+		t.Fatalf("reparse after %+v left declarations out of sync with the source", edit)
+	}
+	return got
+}
+
+// Returns the locations of the assertions in stmt in source order.
+func assertLocations(stmt ast.Stmt) []ast.Location {
+	locations := []ast.Location{}
+	switch s := stmt.(type) {
+	case ast.AssertStmt:
+		locations = append(locations, s.Location)
+	case ast.BlockStmt:
+		for _, inner := range s.Body {
+			locations = append(locations, assertLocations(inner)...)
+		}
+	case ast.FuncDeclStmt:
+		locations = assertLocations(s.Body)
+	case ast.StructDeclStmt:
+		for _, method := range s.Methods {
+			locations = append(locations, assertLocations(method)...)
+		}
+	case ast.IfStmt:
+		locations = append(assertLocations(s.Then), assertLocations(s.Else)...)
+	case ast.ForStmt:
+		locations = assertLocations(s.Body)
+	case ast.ForEachStmt:
+		locations = assertLocations(s.Body)
+	}
+	return locations
+}
+
+func TestReparse(t *testing.T) {
+	src := `struct Point {
+    x: i32,
+    y: i32,
+}
+
+func length(p: Point): i32 {
+    return p.x + p.y;
+}
+
+func main(): void {
+    let p: Point = Point{ x: 1, y: 2 };
+    let n: i32 = length(p);
+}
+`
+	tests := []struct {
+		name string
+		old  string
+		new  string
+	}{
+		{"edit inside function body", "p.x + p.y", "p.x * p.y"},
+		{"edit struct member", "y: i32,\n}", "y: i32,\n    z: i32,\n}"},
+		{"insert declaration between", "}\n\nfunc main", "}\n\nfunc zero(): i32 {\n    return 0;\n}\n\nfunc main"},
+		{"delete declaration", "func length(p: Point): i32 {\n    return p.x + p.y;\n}\n\n", ""},
+		{"edit whitespace between declarations", "}\n\nfunc length", "}\nfunc length"},
+		{"insert at start", "struct Point", "let origin: i32 = 0;\nstruct Point"},
+		{"append at end", "length(p);\n}\n", "length(p);\n}\nlet last: i32 = 1;\n"},
+		{"comment out first line of following declaration", "}\n\nfunc main(): void {\n    let p", "}\n\nfunc main(): void { // let p"},
+		{"comment after closing brace", "}\n\nfunc main(): void {", "} //\n\nfunc main(): void {"},
+		{"replace everything", src, "let a: i32 = 1;\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkReparse(t, ParseDocument(src), replace(t, src, tt.old, tt.new))
+		})
+	}
+}
+
+func TestReparseCommentSpanningDeclarations(t *testing.T) {
+	src := "let a: i32 = 1;\nlet b: i32 = 2; let c: i32 = 3;\nlet d: i32 = 4;\n"
+	start := strings.Index(src, "let b")
+	doc := checkReparse(t, ParseDocument(src), Edit{Start: start, End: start, Text: "// "})
+	if len(doc.Program.Body) != 2 {
+		t.Fatalf("expected 2 declarations, found %d", len(doc.Program.Body))
+	}
+	// Removing the comment brings both declarations back.
+	doc = checkReparse(t, doc, Edit{Start: start, End: start + 3, Text: ""})
+	if len(doc.Program.Body) != 4 {
+		t.Fatalf("expected 4 declarations, found %d", len(doc.Program.Body))
+	}
+}
+
+func TestReparseStringSpanningDeclarations(t *testing.T) {
+	src := "let a: i32 = 1;\nlet b: i32 = 2;\nlet c: i32 = 3; // \";\n"
+	doc := checkReparse(t, ParseDocument(src), replace(t, src, "let a: i32 = 1;", "let a: string = \"1;"))
+	// The string literal now ends at the quote in the comment of the last
+	// declaration.
+	if len(doc.Program.Body) != 1 {
+		t.Fatalf("expected 1 declaration, found %d", len(doc.Program.Body))
+	}
+}
+
+func TestReparseKeepsAssertLocations(t *testing.T) {
+	src := "func main(): void {\n    assert(true);\n}\n\nfunc f(): void { assert(false); }\n"
+	doc := ParseDocument(src)
+	if loc := doc.Program.Body[1].(ast.FuncDeclStmt).Body.Body[0].(ast.AssertStmt).Location; loc != (ast.Location{Line: 1, Column: 18}) {
+		t.Fatalf("expected a location relative to the declaration, found %+v", loc)
+	}
+	doc = checkReparse(t, doc, Edit{Start: 0, End: 0, Text: "let a: i32 = 1;\n\n"})
+	doc = checkReparse(t, doc, replace(t, doc.Source, "\n\nfunc f", "\nlet b: i32 = 2; func f"))
+	assert := doc.Program.Body[3].(ast.FuncDeclStmt).Body.Body[0].(ast.AssertStmt)
+	if loc := doc.Location(3, assert.Location); loc != (ast.Location{Line: 6, Column: 34}) {
+		t.Fatalf("expected the assertion at 6:34, found %+v", loc)
+	}
+}
+
+func TestReparseElseAfterIf(t *testing.T) {
+	src := "if (a) {\n}\nlet b: i32 = 1;\n"
+	start := strings.Index(src, "}") + 1
+	checkReparse(t, ParseDocument(src), Edit{Start: start, End: start, Text: " else {\n}"})
+}
+
+func replace(t *testing.T, src string, old string, new string) Edit {
+	t.Helper()
+	start := strings.Index(src, old)
+	if start < 0 {
+		t.Fatalf("%q not found in source", old)
+	}
+	return Edit{
+		Start: start,
+		End:   start + len(old),
+		Text:  new,
+	}
+}
+
+func TestReparseSequentialEdits(t *testing.T) {
+	doc := ParseDocument("func main(): void {\n}\n")
+	replacements := [][2]string{
+		{"func main", "let x: i32 = 1;\nfunc main"},
+		{"1;", "42;"},
+		{"func main", "func f(): i32 {\n    return x;\n}\nfunc main"},
+		{"return x;", "return x + 1;"},
+		{"let x: i32 = 42;\n", ""},
+		{"x + 1", "1"},
+	}
+	for _, r := range replacements {
+		doc = checkReparse(t, doc, replace(t, doc.Source, r[0], r[1]))
+	}
+}
+
+// Replacing any token of the examples with itself, or inserting trivia before
+// it, must not change the parse.
+func TestReparseExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.jru")
+	if err != nil {
+		t.Fatalf("Failed to find source files : %v", err)
+	}
+	for _, filename := range files {
+		t.Run(filename, func(t *testing.T) {
+			sourceBytes, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatalf("Failed to read file %s : %v", filename, err)
+			}
+			src := string(sourceBytes)
+			doc := ParseDocument(src)
+			_, spans := lexer.TokenizeWithSpans(src)
+			for _, span := range spans {
+				checkReparse(t, doc, Edit{Start: span.Start, End: span.End, Text: src[span.Start:span.End]})
+				checkReparse(t, doc, Edit{Start: span.Start, End: span.Start, Text: "// trivia\n"})
+			}
+		})
+	}
+}
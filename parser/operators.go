@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+)
+
+// ExprParser is the view of the parser that operator parse functions get. It
+// is enough to consume the operands of a new operator, including nested
+// expressions and types.
+type ExprParser interface {
+	Peek() lexer.Token
+	Lookahead(offset int) lexer.Token
+	Consume(expected ...lexer.TokenType) lexer.Token
+	ParseExpr(minBindingPower int) ast.Expr
+	ParseType() ast.Type
+}
+
+// A PrefixParseFunc parses an expression that starts with the already
+// consumed token. bp is the binding power registered for the token.
+type PrefixParseFunc func(p ExprParser, token lexer.Token, bp int) ast.Expr
+
+// An InfixParseFunc parses the rest of an expression whose left hand side has
+// already been parsed, after the operator token has been consumed. rbp is the
+// right binding power registered for the operator.
+type InfixParseFunc func(p ExprParser, lhs ast.Expr, token lexer.Token, rbp int) ast.Expr
+
+type PrefixOperator struct {
+	BindingPower int
+	Parse        PrefixParseFunc
+}
+
+type InfixOperator struct {
+	LeftBindingPower  int
+	RightBindingPower int
+	Parse             InfixParseFunc
+}
+
+// OperatorTable maps token types to the functions that parse them at the head
+// of an expression (prefix) and after a complete expression (infix). Tokens
+// registered as terminators, and tokens not registered as infix operators at
+// all, end an expression without being consumed.
+type OperatorTable struct {
+	prefix map[lexer.TokenType]PrefixOperator
+	infix  map[lexer.TokenType]InfixOperator
+}
+
+// DefaultOperators is the table used by Parse. Registering operators in it
+// affects every later call to Parse; use NewOperatorTable with
+// ParseWithOperators to experiment in isolation.
+var DefaultOperators = NewOperatorTable()
+
+func NewOperatorTable() *OperatorTable {
+	t := &OperatorTable{
+		prefix: make(map[lexer.TokenType]PrefixOperator),
+		infix:  make(map[lexer.TokenType]InfixOperator),
+	}
+	t.registerBuiltins()
+	return t
+}
+
+func (t *OperatorTable) RegisterPrefix(tokenType lexer.TokenType, bp int, parse PrefixParseFunc) {
+	t.prefix[tokenType] = PrefixOperator{
+		BindingPower: bp,
+		Parse:        parse,
+	}
+}
+
+func (t *OperatorTable) RegisterInfix(tokenType lexer.TokenType, lbp int, rbp int, parse InfixParseFunc) {
+	t.infix[tokenType] = InfixOperator{
+		LeftBindingPower:  lbp,
+		RightBindingPower: rbp,
+		Parse:             parse,
+	}
+}
+
+func (t *OperatorTable) RegisterTerminator(tokenType lexer.TokenType) {
+	t.RegisterInfix(tokenType, 0, 0, nil)
+}
+
+func (t *OperatorTable) Prefix(tokenType lexer.TokenType) (PrefixOperator, bool) {
+	op, ok := t.prefix[tokenType]
+	return op, ok
+}
+
+func (t *OperatorTable) Infix(tokenType lexer.TokenType) (InfixOperator, bool) {
+	op, ok := t.infix[tokenType]
+	return op, ok
+}
+
+// Clone returns a copy of the table that can be extended without affecting
+// the original.
+func (t *OperatorTable) Clone() *OperatorTable {
+	clone := &OperatorTable{
+		prefix: make(map[lexer.TokenType]PrefixOperator, len(t.prefix)),
+		infix:  make(map[lexer.TokenType]InfixOperator, len(t.infix)),
+	}
+	for tokenType, op := range t.prefix {
+		clone.prefix[tokenType] = op
+	}
+	for tokenType, op := range t.infix {
+		clone.infix[tokenType] = op
+	}
+	return clone
+}
+
+// A token that is not registered as an infix operator ends the expression
+// like a terminator, so the construct around the expression reports it as
+// unexpected if it does not belong there.
+func (t *OperatorTable) tailPrecedence(tokenType lexer.TokenType) (int, int) {
+	op := t.infix[tokenType]
+	return op.LeftBindingPower, op.RightBindingPower
+}
+
+func (t *OperatorTable) registerBuiltins() {
+	for _, tokenType := range []lexer.TokenType{
+		lexer.EOF,
+		lexer.SEMI_COLON,
+		lexer.CLOSE_PAREN,
+		lexer.COMMA,
+		lexer.CLOSE_CURLY,
+		lexer.CLOSE_BRACKET,
+		lexer.COLON,
+		lexer.DOT_DOT,
+		lexer.ELLIPSIS,
+		lexer.STRING_MIDDLE,
+		lexer.STRING_TAIL,
+		lexer.ELSE,
+	} {
+		t.RegisterTerminator(tokenType)
+	}
+
+	t.RegisterPrefix(lexer.NUMBER, 1, ParseLiteral)
+	t.RegisterPrefix(lexer.STRING, 1, ParseLiteral)
+	t.RegisterPrefix(lexer.TRUE, 1, ParseLiteral)
+	t.RegisterPrefix(lexer.FALSE, 1, ParseLiteral)
+	t.RegisterPrefix(lexer.NONE, 1, ParseLiteral)
+	t.RegisterPrefix(lexer.IDENTIFIER, 1, ParseLiteral)
+	t.RegisterPrefix(lexer.STRING_HEAD, 1, builtinPrefix(func(p *parser, token lexer.Token) ast.Expr {
+		return p.parseInterpolatedStringExpr(token)
+	}))
+	t.RegisterPrefix(lexer.IF, 1, builtinPrefix(func(p *parser, token lexer.Token) ast.Expr {
+		return p.parseIfExpr()
+	}))
+	t.RegisterPrefix(lexer.OPEN_CURLY, 1, builtinPrefix(func(p *parser, token lexer.Token) ast.Expr {
+		return p.parseMapLiteralExpr()
+	}))
+	t.RegisterPrefix(lexer.OPEN_PAREN, 0, parseGroupExpr)
+	t.RegisterPrefix(lexer.PLUS, 10, ParseUnary)
+	t.RegisterPrefix(lexer.DASH, 10, ParseUnary)
+	t.RegisterPrefix(lexer.NOT, 10, ParseUnary)
+
+	for _, tokenType := range []lexer.TokenType{lexer.ASSIGNMENT, lexer.PLUS_EQUALS, lexer.MINUS_EQUALS} {
+		t.RegisterInfix(tokenType, 1, 2, parseAssignExpr)
+	}
+	binaryOperators := []struct {
+		tokenTypes []lexer.TokenType
+		lbp, rbp   int
+	}{
+		{[]lexer.TokenType{lexer.OR, lexer.AND}, 4, 3},
+		{[]lexer.TokenType{lexer.EQUALS, lexer.NOT_EQUALS}, 5, 6},
+		{[]lexer.TokenType{lexer.LESS, lexer.LESS_EQUALS, lexer.GREATER, lexer.GREATER_EQUALS}, 8, 7},
+		{[]lexer.TokenType{lexer.QUESTION_QUESTION}, 9, 8},
+		{[]lexer.TokenType{lexer.PLUS, lexer.DASH}, 10, 9},
+		{[]lexer.TokenType{lexer.STAR, lexer.SLASH, lexer.PERCENT}, 12, 11},
+	}
+	for _, op := range binaryOperators {
+		for _, tokenType := range op.tokenTypes {
+			t.RegisterInfix(tokenType, op.lbp, op.rbp, ParseBinary)
+		}
+	}
+	t.RegisterInfix(lexer.OPEN_CURLY, 13, 0, builtinInfix(func(p *parser, lhs ast.Expr) ast.Expr {
+		return p.parseStructLiteralExpr(lhs)
+	}))
+	t.RegisterInfix(lexer.OPEN_PAREN, 14, 0, builtinInfix(func(p *parser, lhs ast.Expr) ast.Expr {
+		return p.parseFuncCallExpr(lhs)
+	}))
+	t.RegisterInfix(lexer.OPEN_BRACKET, 14, 0, builtinInfix(func(p *parser, lhs ast.Expr) ast.Expr {
+		return p.parseArrayIndexExpr(lhs)
+	}))
+	t.RegisterInfix(lexer.QUESTION, 14, 0, func(p ExprParser, lhs ast.Expr, token lexer.Token, rbp int) ast.Expr {
+		return ast.TryExpr{
+			Expr: lhs,
+		}
+	})
+	t.RegisterInfix(lexer.DOT, 16, 15, builtinInfix(func(p *parser, lhs ast.Expr) ast.Expr {
+		return p.parseStructMemberExpr(lhs)
+	}))
+	t.RegisterInfix(lexer.QUESTION_DOT, 16, 15, builtinInfix(func(p *parser, lhs ast.Expr) ast.Expr {
+		return p.parseOptionalMemberExpr(lhs)
+	}))
+}
+
+func builtinPrefix(parse func(p *parser, token lexer.Token) ast.Expr) PrefixParseFunc {
+	return func(p ExprParser, token lexer.Token, bp int) ast.Expr {
+		return parse(p.(*parser), token)
+	}
+}
+
+func builtinInfix(parse func(p *parser, lhs ast.Expr) ast.Expr) InfixParseFunc {
+	return func(p ExprParser, lhs ast.Expr, token lexer.Token, rbp int) ast.Expr {
+		return parse(p.(*parser), lhs)
+	}
+}
+
+// ParseLiteral parses a single token literal or identifier.
+func ParseLiteral(p ExprParser, token lexer.Token, bp int) ast.Expr {
+	switch token.Type {
+	case lexer.NUMBER:
+		return ast.NumberLiteralExpr{
+			Value: token.Value,
+		}
+	case lexer.STRING:
+		return ast.StringLiteralExpr{
+			Value: token.Value,
+		}
+	case lexer.TRUE, lexer.FALSE:
+		return ast.BoolLiteralExpr{
+			Value: (token.Type == lexer.TRUE),
+		}
+	case lexer.NONE:
+		return ast.NoneLiteralExpr{}
+	default:
+		return ast.IdentExpr{
+			Value: token.Value,
+		}
+	}
+}
+
+// ParseUnary parses a prefix operator applied to the expression that follows.
+func ParseUnary(p ExprParser, token lexer.Token, bp int) ast.Expr {
+	rhs := p.ParseExpr(bp)
+	return ast.UnaryExpr{
+		Operator: token,
+		Rhs:      rhs,
+	}
+}
+
+// ParseBinary parses the right hand side of an infix operator.
+func ParseBinary(p ExprParser, lhs ast.Expr, token lexer.Token, rbp int) ast.Expr {
+	rhs := p.ParseExpr(rbp)
+	return ast.BinaryExpr{
+		Lhs:      lhs,
+		Operator: token,
+		Rhs:      rhs,
+	}
+}
+
+func parseAssignExpr(p ExprParser, lhs ast.Expr, token lexer.Token, rbp int) ast.Expr {
+	rhs := p.ParseExpr(rbp)
+	return ast.AssignExpr{
+		Assigne:       lhs,
+		Operator:      token,
+		AssignedValue: rhs,
+	}
+}
+
+func parseGroupExpr(p ExprParser, token lexer.Token, bp int) ast.Expr {
+	rhs := p.ParseExpr(bp)
+	if p.Peek().Type == lexer.COMMA {
+		elems := []ast.Expr{rhs}
+		for p.Peek().Type == lexer.COMMA {
+			p.Consume(lexer.COMMA)
+			elems = append(elems, p.ParseExpr(bp))
+		}
+		p.Consume(lexer.CLOSE_PAREN)
+		return ast.TupleExpr{
+			Elems: elems,
+		}
+	}
+	p.Consume(lexer.CLOSE_PAREN)
+	return ast.GroupExpr{
+		Expr: rhs,
+	}
+}
+
+func (p *parser) Peek() lexer.Token {
+	return p.peek()
+}
+
+func (p *parser) Lookahead(offset int) lexer.Token {
+	return p.lookahead(offset)
+}
+
+func (p *parser) Consume(expected ...lexer.TokenType) lexer.Token {
+	return p.consume(expected...)
+}
+
+func (p *parser) ParseExpr(minBindingPower int) ast.Expr {
+	return p.parseExpr(minBindingPower)
+}
+
+func (p *parser) ParseType() ast.Type {
+	return p.parseType()
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func expectParseError(t *testing.T, want string, parse func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if r == nil {
+			t.Fatalf("expected a parse error containing %q", want)
+		}
+		if msg, _ := r.(string); !strings.Contains(msg, want) {
+			t.Fatalf("expected a parse error containing %q, got %v", want, r)
+		}
+	}()
+	parse()
+}
+
+func TestCustomOperatorsOnClone(t *testing.T) {
+	operators := DefaultOperators.Clone()
+	// A range operator binding looser than + but tighter than comparisons.
+	operators.RegisterInfix(lexer.DOT_DOT, 9, 8, ParseBinary)
+	operators.RegisterPrefix(lexer.AT, 10, func(p ExprParser, token lexer.Token, bp int) ast.Expr {
+		return ast.FuncCallExpr{
+			Func: ast.IdentExpr{Value: "len"},
+			Args: []ast.Expr{p.ParseExpr(bp)},
+		}
+	})
+
+	program := ParseWithOperators(lexer.Tokenize("a .. @b + 1;"), operators)
+	want := ast.BinaryExpr{
+		Lhs:      ast.IdentExpr{Value: "a"},
+		Operator: lexer.Token{Type: lexer.DOT_DOT, Value: ".."},
+		Rhs: ast.BinaryExpr{
+			Lhs: ast.FuncCallExpr{
+				Func: ast.IdentExpr{Value: "len"},
+				Args: []ast.Expr{ast.IdentExpr{Value: "b"}},
+			},
+			Operator: lexer.Token{Type: lexer.PLUS, Value: "+"},
+			Rhs:      ast.NumberLiteralExpr{Value: "1"},
+		},
+	}
+	if got := program.Body[0].(ast.ExpressionStmt).Expr; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected expression %+v", got)
+	}
+
+	if op, ok := DefaultOperators.Infix(lexer.DOT_DOT); !ok || op.Parse != nil {
+		t.Fatalf("registering on a clone changed the default table")
+	}
+	if _, ok := DefaultOperators.Prefix(lexer.AT); ok {
+		t.Fatalf("registering on a clone changed the default table")
+	}
+	expectParseError(t, "Expected [semi_colon], found dot_dot", func() {
+		Parse(lexer.Tokenize("a .. b;"))
+	})
+}
+
+func TestRegisterTerminator(t *testing.T) {
+	operators := DefaultOperators.Clone()
+	operators.RegisterTerminator(lexer.PLUS)
+	expectParseError(t, "Expected [semi_colon], found plus", func() {
+		ParseWithOperators(lexer.Tokenize("a + b;"), operators)
+	})
+	if program := Parse(lexer.Tokenize("a + b;")); len(program.Body) != 1 {
+		t.Fatalf("unexpected program %+v", program)
+	}
+}
+
+func TestUnregisteredTailTokenIsAParseError(t *testing.T) {
+	expectParseError(t, "Expected [semi_colon], found identifier", func() {
+		Parse(lexer.Tokenize("let x = a b;"))
+	})
+}
@@ -5,11 +5,19 @@ import (
 	"github.com/ruistola/compiler-proto/ast"
 	"github.com/ruistola/compiler-proto/lexer"
 	"slices"
+	"strings"
 )
 
 type parser struct {
-	tokens []lexer.Token
-	pos    int
+	tokens    []lexer.Token
+	pos       int
+	operators *OperatorTable
+	cst       *cstBuilder
+	src       string
+	spans     []lexer.Span
+	// The offset in src that locations are relative to. Documents record
+	// locations relative to the start of each top level declaration.
+	locationBase int
 }
 
 func (p *parser) peek() lexer.Token {
@@ -20,57 +28,34 @@ func (p *parser) peek() lexer.Token {
 	return result
 }
 
+func (p *parser) lookahead(offset int) lexer.Token {
+	result := lexer.Token{}
+	if p.pos+offset < len(p.tokens) {
+		result = p.tokens[p.pos+offset]
+	}
+	return result
+}
+
 func (p *parser) consume(expected ...lexer.TokenType) lexer.Token {
 	token := p.peek()
 	if len(expected) > 0 && !slices.Contains(expected, token.Type) {
 		panic(fmt.Sprintf("Expected %s, found %s\n", expected, token.Type))
 	}
+	p.cst.token(p.pos)
 	p.pos++
 	return token
 }
 
-func headPrecedence(tokenType lexer.TokenType) int {
-	switch tokenType {
-	case lexer.EOF, lexer.SEMI_COLON, lexer.OPEN_PAREN:
-		return 0
-	case lexer.NUMBER, lexer.STRING, lexer.WORD, lexer.TRUE, lexer.FALSE:
-		return 1
-	case lexer.PLUS, lexer.DASH:
-		return 10
-	default:
-		panic(fmt.Sprintf("Cannot determine binding power for '%s' as a head token", tokenType))
-	}
-}
-
-func tailPrecedence(tokenType lexer.TokenType) (int, int) {
-	switch tokenType {
-	case lexer.EOF, lexer.SEMI_COLON, lexer.CLOSE_PAREN, lexer.COMMA, lexer.CLOSE_CURLY, lexer.CLOSE_BRACKET:
-		return 0, 0
-	case lexer.ASSIGNMENT, lexer.PLUS_EQUALS, lexer.MINUS_EQUALS:
-		return 1, 2
-	case lexer.OR, lexer.AND:
-		return 4, 3
-	case lexer.EQUALS, lexer.NOT_EQUALS:
-		return 5, 6
-	case lexer.LESS, lexer.LESS_EQUALS, lexer.GREATER, lexer.GREATER_EQUALS:
-		return 8, 7
-	case lexer.PLUS, lexer.DASH:
-		return 10, 9
-	case lexer.STAR, lexer.SLASH, lexer.PERCENT:
-		return 12, 11
-	case lexer.OPEN_CURLY:
-		return 13, 0
-	case lexer.OPEN_PAREN, lexer.OPEN_BRACKET:
-		return 14, 0
-	case lexer.DOT:
-		return 16, 15
-	default:
-		panic(fmt.Sprintf("Cannot determine binding power for '%s' as a tail token", tokenType))
-	}
+func Parse(tokens []lexer.Token) ast.BlockStmt {
+	return ParseWithOperators(tokens, DefaultOperators)
 }
 
-func Parse(tokens []lexer.Token) ast.BlockStmt {
-	p := parser{tokens, 0}
+func ParseWithOperators(tokens []lexer.Token, operators *OperatorTable) ast.BlockStmt {
+	p := parser{tokens: tokens, operators: operators}
+	return p.parseProgram()
+}
+
+func (p *parser) parseProgram() ast.BlockStmt {
 	program := ast.BlockStmt{}
 	for p.peek().Type != lexer.EOF {
 		program.Body = append(program.Body, p.parseStmt())
@@ -78,15 +63,74 @@ func Parse(tokens []lexer.Token) ast.BlockStmt {
 	return program
 }
 
+// Like Parse, but records the source locations of the statements that need
+// them, using the token spans from lexer.TokenizeWithSpans.
+func ParseWithSpans(src string, tokens []lexer.Token, spans []lexer.Span) ast.BlockStmt {
+	p := parser{tokens: tokens, operators: DefaultOperators, src: src, spans: spans}
+	return p.parseProgram()
+}
+
+func (p *parser) location() ast.Location {
+	if p.pos >= len(p.spans) {
+		return ast.Location{}
+	}
+	text := p.src[p.locationBase:p.spans[p.pos].Start]
+	lineStart := strings.LastIndexByte(text, '\n') + 1
+	return ast.Location{
+		Line:   strings.Count(text, "\n") + 1,
+		Column: len(text) - lineStart + 1,
+	}
+}
+
+func ParseExpr(tokens []lexer.Token) ast.Expr {
+	p := parser{tokens: tokens, operators: DefaultOperators}
+	expr := p.parseExpr(0)
+	p.expectEnd("expression")
+	return expr
+}
+
+func ParseType(tokens []lexer.Token) ast.Type {
+	p := parser{tokens: tokens, operators: DefaultOperators}
+	t := p.parseType()
+	p.expectEnd("type")
+	return t
+}
+
+func ParseStmt(tokens []lexer.Token) ast.Stmt {
+	p := parser{tokens: tokens, operators: DefaultOperators}
+	stmt := p.parseStmt()
+	p.expectEnd("statement")
+	return stmt
+}
+
+func (p *parser) expectEnd(parsed string) {
+	if token := p.peek(); token.Type != lexer.EOF {
+		panic(fmt.Sprintf("Unexpected %s after %s\n", token.Type, parsed))
+	}
+}
+
 func (p *parser) parseStmt() ast.Stmt {
+	checkpoint := p.cst.checkpoint()
+	stmt := p.parseStmtKind()
+	p.cst.wrap(checkpoint, stmt)
+	return stmt
+}
+
+func (p *parser) parseStmtKind() ast.Stmt {
 	switch p.peek().Type {
 	case lexer.OPEN_CURLY:
 		return p.parseBlockStmt()
+	case lexer.AT:
+		return p.parseAttributedStmt()
 	case lexer.LET:
 		return p.parseVarDeclStmt()
 	case lexer.STRUCT:
 		return p.parseStructDeclStmt()
-	case lexer.FUNC:
+	case lexer.INTERFACE:
+		return p.parseInterfaceDeclStmt()
+	case lexer.TYPE, lexer.NEWTYPE:
+		return p.parseTypeDeclStmt()
+	case lexer.FUNC, lexer.EXTERN:
 		return p.parseFuncDeclStmt()
 	case lexer.IF:
 		return p.parseIfStmt()
@@ -94,146 +138,181 @@ func (p *parser) parseStmt() ast.Stmt {
 		return p.parseForStmt()
 	case lexer.RETURN:
 		return p.parseReturnStmt()
+	case lexer.DEFER:
+		return p.parseDeferStmt()
+	case lexer.STATIC_ASSERT:
+		return p.parseStaticAssertStmt()
+	case lexer.ASSERT:
+		return p.parseAssertStmt()
 	default:
 		return p.parseExpressionStmt()
 	}
 }
 
 func (p *parser) parseExpr(min_bp int) ast.Expr {
+	checkpoint := p.cst.checkpoint()
 	token := p.consume()
 	leftExpr := p.parseHeadExpr(token)
+	p.cst.wrap(checkpoint, leftExpr)
 	for {
 		nextToken := p.peek()
-		if lbp, rbp := tailPrecedence(nextToken.Type); lbp <= min_bp {
+		if lbp, rbp := p.operators.tailPrecedence(nextToken.Type); lbp <= min_bp {
 			break
 		} else {
 			leftExpr = p.parseTailExpr(leftExpr, rbp)
+			p.cst.wrap(checkpoint, leftExpr)
 		}
 	}
 	return leftExpr
 }
 
 func (p *parser) parseHeadExpr(token lexer.Token) ast.Expr {
-	switch token.Type {
-	case lexer.NUMBER:
-		return ast.NumberLiteralExpr{
-			Value: token.Value,
-		}
-	case lexer.STRING:
-		return ast.StringLiteralExpr{
-			Value: token.Value,
-		}
-	case lexer.IDENTIFIER:
-		return ast.IdentExpr{
-			Value: token.Value,
-		}
-	case lexer.TRUE, lexer.FALSE:
-		return ast.BoolLiteralExpr{
-			Value: (token.Type == lexer.TRUE),
-		}
-	case lexer.PLUS, lexer.DASH:
-		rbp := headPrecedence(token.Type)
-		rhs := p.parseExpr(rbp)
-		return ast.UnaryExpr{
-			Operator: token,
-			Rhs:      rhs,
-		}
-	case lexer.OPEN_PAREN:
-		rbp := headPrecedence(token.Type)
-		rhs := p.parseExpr(rbp)
-		p.consume(lexer.CLOSE_PAREN)
-		return ast.GroupExpr{
-			Expr: rhs,
-		}
-	default:
+	op, ok := p.operators.Prefix(token.Type)
+	if !ok || op.Parse == nil {
 		panic(fmt.Sprintf("Failed to parse head expression from token %v\n", token))
 	}
+	return op.Parse(p, token, op.BindingPower)
 }
 
 func (p *parser) parseTailExpr(head ast.Expr, rbp int) ast.Expr {
 	token := p.consume()
-	switch token.Type {
-	case lexer.ASSIGNMENT, lexer.PLUS_EQUALS, lexer.MINUS_EQUALS:
-		rhs := p.parseExpr(rbp)
-		return ast.AssignExpr{
-			Assigne:       head,
-			Operator:      token,
-			AssignedValue: rhs,
-		}
-	case lexer.PLUS,
-		lexer.DASH,
-		lexer.STAR,
-		lexer.SLASH,
-		lexer.PERCENT,
-		lexer.LESS,
-		lexer.LESS_EQUALS,
-		lexer.GREATER,
-		lexer.GREATER_EQUALS:
-		rhs := p.parseExpr(rbp)
-		return ast.BinaryExpr{
-			Lhs:      head,
-			Operator: token,
-			Rhs:      rhs,
-		}
-	case lexer.OPEN_PAREN:
-		return p.parseFuncCallExpr(head)
-	case lexer.OPEN_CURLY:
-		return p.parseStructLiteralExpr(head)
-	case lexer.OPEN_BRACKET:
-		return p.parseArrayIndexExpr(head)
-	case lexer.DOT:
-		return p.parseStructMemberExpr(head)
-	default:
+	op, ok := p.operators.Infix(token.Type)
+	if !ok || op.Parse == nil {
 		panic(fmt.Sprintf("Failed to parse tail expression from token %v\n", token))
 	}
+	return op.Parse(p, head, token, rbp)
 }
 
-func (p *parser) parseArrayType(innerType ast.Type) ast.Type {
-	p.consume(lexer.OPEN_BRACKET)
-	p.consume(lexer.CLOSE_BRACKET)
-	arrayType := ast.ArrayType{
-		UnderlyingType: innerType,
-	}
-	if p.peek().Type == lexer.OPEN_BRACKET {
-		return p.parseArrayType(arrayType)
+func (p *parser) parseTypeSuffix(innerType ast.Type) ast.Type {
+	switch p.peek().Type {
+	case lexer.OPEN_BRACKET:
+		p.consume(lexer.OPEN_BRACKET)
+		p.consume(lexer.CLOSE_BRACKET)
+		return p.parseTypeSuffix(ast.ArrayType{
+			UnderlyingType: innerType,
+		})
+	case lexer.QUESTION:
+		p.consume(lexer.QUESTION)
+		return p.parseTypeSuffix(ast.OptionalType{
+			UnderlyingType: innerType,
+		})
+	default:
+		return innerType
 	}
-	return arrayType
 }
 
 func (p *parser) parseType() ast.Type {
+	checkpoint := p.cst.checkpoint()
+	t := p.parseTypeKind()
+	p.cst.wrap(checkpoint, t)
+	return t
+}
+
+func (p *parser) parseTypeKind() ast.Type {
+	return p.parseTypeSuffix(p.parseBaseType())
+}
+
+// Parses a type without the array and optional suffixes that may follow it.
+func (p *parser) parseBaseType() ast.Type {
 	if p.peek().Type == lexer.FUNC {
 		return p.parseFuncType()
 	}
+	if p.peek().Type == lexer.OPEN_PAREN {
+		return p.parseTupleType()
+	}
+	if p.peek().Value == "Result" && p.lookahead(1).Type == lexer.LESS {
+		return p.parseResultType()
+	}
+	if p.peek().Value == "map" && p.lookahead(1).Type == lexer.OPEN_BRACKET {
+		return p.parseMapType()
+	}
 	name := p.consume(lexer.IDENTIFIER).Value
-	namedType := ast.NamedType{
+	for p.peek().Type == lexer.DOT {
+		p.consume(lexer.DOT)
+		name += "." + p.consume(lexer.IDENTIFIER).Value
+	}
+	return ast.NamedType{
 		TypeName: name,
 	}
-	if p.peek().Type == lexer.OPEN_BRACKET {
-		return p.parseArrayType(namedType)
+}
+
+func (p *parser) parseResultType() ast.ResultType {
+	p.consume(lexer.IDENTIFIER)
+	p.consume(lexer.LESS)
+	valueType := p.parseType()
+	p.consume(lexer.COMMA)
+	errType := p.parseType()
+	p.consume(lexer.GREATER)
+	return ast.ResultType{
+		ValueType: valueType,
+		ErrType:   errType,
+	}
+}
+
+func (p *parser) parseMapType() ast.MapType {
+	p.consume(lexer.IDENTIFIER)
+	p.consume(lexer.OPEN_BRACKET)
+	keyType := p.parseType()
+	p.consume(lexer.CLOSE_BRACKET)
+	// Suffixes after the value type apply to the whole map, so map[K]V[] is an
+	// array of maps; an array value type is written map[K](V[]).
+	checkpoint := p.cst.checkpoint()
+	valueType := p.parseBaseType()
+	p.cst.wrap(checkpoint, valueType)
+	return ast.MapType{
+		KeyType:   keyType,
+		ValueType: valueType,
+	}
+}
+
+// A parenthesized single type is just that type, the same way a parenthesized
+// single expression is a group rather than a tuple.
+func (p *parser) parseTupleType() ast.Type {
+	p.consume(lexer.OPEN_PAREN)
+	elemTypes := []ast.Type{p.parseType()}
+	for p.peek().Type == lexer.COMMA {
+		p.consume(lexer.COMMA)
+		elemTypes = append(elemTypes, p.parseType())
+	}
+	p.consume(lexer.CLOSE_PAREN)
+	if len(elemTypes) == 1 {
+		return elemTypes[0]
+	}
+	return ast.TupleType{
+		ElemTypes: elemTypes,
 	}
-	return namedType
 }
 
 func (p *parser) parseFuncType() ast.FuncType {
 	p.consume(lexer.FUNC)
+	return p.parseFuncSignatureType()
+}
+
+func (p *parser) parseFuncSignatureType() ast.FuncType {
 	p.consume(lexer.OPEN_PAREN)
 	paramTypes := []ast.Type{}
+	variadic := false
 	for p.peek().Type != lexer.CLOSE_PAREN {
 		if p.peek().Type == lexer.IDENTIFIER {
 			name := p.consume(lexer.IDENTIFIER).Value
 			if p.peek().Type == lexer.COLON {
 				p.consume(lexer.COLON)
+				variadic = p.parseVariadicMarker()
 				paramType := p.parseType()
 				paramTypes = append(paramTypes, paramType)
 			} else {
-				paramTypes = append(paramTypes, ast.NamedType{
+				paramTypes = append(paramTypes, p.parseTypeSuffix(ast.NamedType{
 					TypeName: name,
-				})
+				}))
 			}
 		} else {
+			variadic = p.parseVariadicMarker()
 			paramType := p.parseType()
 			paramTypes = append(paramTypes, paramType)
 		}
+		if variadic {
+			p.expectLastParam()
+		}
 		if p.peek().Type == lexer.COMMA {
 			p.consume(lexer.COMMA)
 		} else {
@@ -251,14 +330,38 @@ func (p *parser) parseFuncType() ast.FuncType {
 	return ast.FuncType{
 		ReturnType: returnType,
 		ParamTypes: paramTypes,
+		Variadic:   variadic,
+	}
+}
+
+func (p *parser) parseVariadicMarker() bool {
+	if p.peek().Type != lexer.ELLIPSIS {
+		return false
 	}
+	p.consume(lexer.ELLIPSIS)
+	return true
 }
 
-func (p *parser) parseVarDeclStmt() ast.VarDeclStmt {
+func (p *parser) expectLastParam() {
+	if p.peek().Type == lexer.COMMA && p.lookahead(1).Type == lexer.CLOSE_PAREN {
+		return
+	}
+	if p.peek().Type != lexer.CLOSE_PAREN {
+		panic("Variadic parameter must be the last parameter\n")
+	}
+}
+
+func (p *parser) parseVarDeclStmt() ast.Stmt {
 	p.consume(lexer.LET)
+	if p.peek().Type == lexer.OPEN_PAREN {
+		return p.parseTupleDeclStmt()
+	}
 	varName := p.consume(lexer.IDENTIFIER).Value
-	p.consume(lexer.COLON)
-	varType := p.parseType()
+	var varType ast.Type
+	if p.peek().Type == lexer.COLON {
+		p.consume(lexer.COLON)
+		varType = p.parseType()
+	}
 	var initVal ast.Expr
 	if p.peek().Type != lexer.SEMI_COLON {
 		p.consume(lexer.ASSIGNMENT)
@@ -274,18 +377,64 @@ func (p *parser) parseVarDeclStmt() ast.VarDeclStmt {
 	}
 }
 
+func (p *parser) parseTupleDeclStmt() ast.TupleDeclStmt {
+	p.consume(lexer.OPEN_PAREN)
+	vars := make([]ast.TypedIdent, 0)
+	for p.peek().Type != lexer.CLOSE_PAREN {
+		varName := p.consume(lexer.IDENTIFIER).Value
+		var varType ast.Type
+		if p.peek().Type == lexer.COLON {
+			p.consume(lexer.COLON)
+			varType = p.parseType()
+		}
+		vars = append(vars, ast.TypedIdent{
+			Name: varName,
+			Type: varType,
+		})
+		if p.peek().Type == lexer.COMMA {
+			p.consume(lexer.COMMA)
+		} else {
+			break
+		}
+	}
+	p.consume(lexer.CLOSE_PAREN)
+	p.consume(lexer.ASSIGNMENT)
+	initVal := p.parseExpr(0)
+	p.consume(lexer.SEMI_COLON)
+	return ast.TupleDeclStmt{
+		Vars:    vars,
+		InitVal: initVal,
+	}
+}
+
 func (p *parser) parseFuncDeclStmt() ast.FuncDeclStmt {
+	extern := false
+	if p.peek().Type == lexer.EXTERN {
+		p.consume(lexer.EXTERN)
+		extern = true
+	}
 	p.consume(lexer.FUNC)
 	name := p.consume(lexer.IDENTIFIER).Value
 	p.consume(lexer.OPEN_PAREN)
-	params := make([]ast.TypedIdent, 0)
+	params := make([]ast.Parameter, 0)
 	for p.peek().Type != lexer.CLOSE_PAREN {
 		paramName := p.consume(lexer.IDENTIFIER).Value
 		p.consume(lexer.COLON)
+		variadic := p.parseVariadicMarker()
 		paramType := p.parseType()
-		params = append(params, ast.TypedIdent{
-			Name: paramName,
-			Type: paramType,
+		var defaultValue ast.Expr
+		if p.peek().Type == lexer.ASSIGNMENT {
+			p.consume(lexer.ASSIGNMENT)
+			defaultValue = p.parseExpr(0)
+		}
+		if variadic {
+			p.expectLastParam()
+		}
+		params = append(params, ast.Parameter{
+			Name:     paramName,
+			Type:     paramType,
+			Default:  defaultValue,
+			Variadic: variadic,
 		})
 		if p.peek().Type == lexer.COMMA {
 			p.consume(lexer.COMMA)
@@ -297,8 +446,14 @@ func (p *parser) parseFuncDeclStmt() ast.FuncDeclStmt {
 		p.consume(lexer.COLON)
 		returnType = p.parseType()
 	}
-	funcBody := p.parseBlockStmt()
+	var funcBody ast.BlockStmt
+	if extern {
+		p.consume(lexer.SEMI_COLON)
+	} else {
+		funcBody = p.parseBlockStmt()
+	}
 	return ast.FuncDeclStmt{
+		Extern:     extern,
 		Name:       name,
 		Parameters: params,
 		ReturnType: returnType,
@@ -306,18 +461,104 @@ func (p *parser) parseFuncDeclStmt() ast.FuncDeclStmt {
 	}
 }
 
+func (p *parser) parseAttributes() []ast.Attribute {
+	attributes := []ast.Attribute{}
+	for p.peek().Type == lexer.AT {
+		p.consume(lexer.AT)
+		name := p.consume(lexer.IDENTIFIER, lexer.EXTERN).Value
+		args := []ast.Expr{}
+		if p.peek().Type == lexer.OPEN_PAREN {
+			p.consume(lexer.OPEN_PAREN)
+			for p.peek().Type != lexer.CLOSE_PAREN {
+				args = append(args, p.parseExpr(0))
+				if p.peek().Type == lexer.COMMA {
+					p.consume(lexer.COMMA)
+				} else {
+					break
+				}
+			}
+			p.consume(lexer.CLOSE_PAREN)
+		}
+		attributes = append(attributes, ast.Attribute{
+			Name: name,
+			Args: args,
+		})
+	}
+	return attributes
+}
+
+func (p *parser) parseAttributedStmt() ast.Stmt {
+	attributes := p.parseAttributes()
+	switch p.peek().Type {
+	case lexer.FUNC, lexer.EXTERN:
+		funcDecl := p.parseFuncDeclStmt()
+		funcDecl.Attributes = attributes
+		return funcDecl
+	case lexer.STRUCT:
+		structDecl := p.parseStructDeclStmt()
+		structDecl.Attributes = attributes
+		return structDecl
+	default:
+		panic(fmt.Sprintf("Attributes can only be applied to function and struct declarations, found %s\n", p.peek().Type))
+	}
+}
+
 func (p *parser) parseStructDeclStmt() ast.StructDeclStmt {
 	p.consume(lexer.STRUCT)
 	name := p.consume(lexer.IDENTIFIER).Value
 	p.consume(lexer.OPEN_CURLY)
-	members := make([]ast.TypedIdent, 0)
+	members := make([]ast.StructMember, 0)
+	methods := make([]ast.FuncDeclStmt, 0)
+	nestedStructs := make([]ast.StructDeclStmt, 0)
 	for p.peek().Type != lexer.CLOSE_CURLY {
+		attributes := p.parseAttributes()
+		if p.peek().Type == lexer.FUNC {
+			method := p.parseFuncDeclStmt()
+			method.Attributes = attributes
+			methods = append(methods, method)
+			if p.peek().Type == lexer.COMMA {
+				p.consume(lexer.COMMA)
+			}
+			continue
+		}
+		if p.peek().Type == lexer.STRUCT {
+			nestedStruct := p.parseStructDeclStmt()
+			nestedStruct.Attributes = attributes
+			nestedStructs = append(nestedStructs, nestedStruct)
+			if p.peek().Type == lexer.COMMA {
+				p.consume(lexer.COMMA)
+			}
+			continue
+		}
+		if p.lookahead(1).Type != lexer.COLON {
+			embeddedType, ok := p.parseType().(ast.NamedType)
+			if !ok {
+				panic("Only named struct types can be embedded\n")
+			}
+			members = append(members, ast.StructMember{
+				Attributes: attributes,
+				Name:       embeddedType.TypeName[strings.LastIndex(embeddedType.TypeName, ".")+1:],
+				Type:       embeddedType,
+				Embedded:   true,
+			})
+			if p.peek().Type == lexer.COMMA {
+				p.consume(lexer.COMMA)
+			}
+			continue
+		}
 		memberName := p.consume(lexer.IDENTIFIER).Value
 		p.consume(lexer.COLON)
 		memberType := p.parseType()
-		newMember := ast.TypedIdent{
-			Name: memberName,
-			Type: memberType,
+		var defaultVal ast.Expr
+		if p.peek().Type == lexer.ASSIGNMENT {
+			p.consume(lexer.ASSIGNMENT)
+			defaultVal = p.parseExpr(0)
+		}
+		newMember := ast.StructMember{
+			Attributes: attributes,
+			Name:       memberName,
+			Type:       memberType,
+			Default:    defaultVal,
 		}
 		members = append(members, newMember)
 		if p.peek().Type == lexer.COMMA {
@@ -326,8 +567,44 @@ func (p *parser) parseStructDeclStmt() ast.StructDeclStmt {
 	}
 	p.consume(lexer.CLOSE_CURLY)
 	return ast.StructDeclStmt{
+		Name:          name,
+		Members:       members,
+		Methods:       methods,
+		NestedStructs: nestedStructs,
+	}
+}
+
+func (p *parser) parseInterfaceDeclStmt() ast.InterfaceDeclStmt {
+	p.consume(lexer.INTERFACE)
+	name := p.consume(lexer.IDENTIFIER).Value
+	p.consume(lexer.OPEN_CURLY)
+	methods := make([]ast.TypedIdent, 0)
+	for p.peek().Type != lexer.CLOSE_CURLY {
+		methodName := p.consume(lexer.IDENTIFIER).Value
+		methodType := p.parseFuncSignatureType()
+		p.consume(lexer.SEMI_COLON)
+		methods = append(methods, ast.TypedIdent{
+			Name: methodName,
+			Type: methodType,
+		})
+	}
+	p.consume(lexer.CLOSE_CURLY)
+	return ast.InterfaceDeclStmt{
 		Name:    name,
-		Members: members,
+		Methods: methods,
+	}
+}
+
+func (p *parser) parseTypeDeclStmt() ast.TypeDeclStmt {
+	keyword := p.consume(lexer.TYPE, lexer.NEWTYPE)
+	name := p.consume(lexer.IDENTIFIER).Value
+	p.consume(lexer.ASSIGNMENT)
+	declType := p.parseType()
+	p.consume(lexer.SEMI_COLON)
+	return ast.TypeDeclStmt{
+		Name:     name,
+		Type:     declType,
+		Distinct: keyword.Type == lexer.NEWTYPE,
 	}
 }
 
@@ -349,9 +626,31 @@ func (p *parser) parseIfStmt() ast.Stmt {
 	}
 }
 
+func (p *parser) parseIfExpr() ast.IfExpr {
+	p.consume(lexer.OPEN_PAREN)
+	cond := p.parseExpr(0)
+	p.consume(lexer.CLOSE_PAREN)
+	thenExpr := p.parseExpr(0)
+	var elseExpr ast.Expr
+	if p.peek().Type == lexer.ELSE {
+		p.consume(lexer.ELSE)
+		elseExpr = p.parseExpr(0)
+	}
+	return ast.IfExpr{
+		Cond: cond,
+		Then: thenExpr,
+		Else: elseExpr,
+	}
+}
+
 func (p *parser) parseForStmt() ast.Stmt {
 	p.consume(lexer.FOR)
 	p.consume(lexer.OPEN_PAREN)
+	if p.peek().Type == lexer.IDENTIFIER {
+		if next := p.lookahead(1).Type; next == lexer.IN || next == lexer.COMMA {
+			return p.parseForEachStmt()
+		}
+	}
 	initStmt := p.parseStmt()
 	condExpr := p.parseExpressionStmt().(ast.ExpressionStmt).Expr
 	iterStmt := ast.ExpressionStmt{Expr: p.parseExpr(0)}
@@ -365,10 +664,70 @@ func (p *parser) parseForStmt() ast.Stmt {
 	}
 }
 
+func (p *parser) parseForEachStmt() ast.ForEachStmt {
+	index := ""
+	elem := p.consume(lexer.IDENTIFIER).Value
+	if p.peek().Type == lexer.COMMA {
+		p.consume(lexer.COMMA)
+		index = elem
+		elem = p.consume(lexer.IDENTIFIER).Value
+	}
+	p.consume(lexer.IN)
+	iterable := p.parseExpr(0)
+	if p.peek().Type == lexer.DOT_DOT {
+		p.consume(lexer.DOT_DOT)
+		iterable = ast.RangeExpr{
+			Start: iterable,
+			End:   p.parseExpr(0),
+		}
+	}
+	p.consume(lexer.CLOSE_PAREN)
+	body := p.parseBlockStmt()
+	return ast.ForEachStmt{
+		Index:    index,
+		Elem:     elem,
+		Iterable: iterable,
+		Body:     body,
+	}
+}
+
+func (p *parser) parseInterpolatedStringExpr(head lexer.Token) ast.InterpolatedStringExpr {
+	parts := []string{head.Value}
+	exprs := []ast.Expr{}
+	for {
+		exprs = append(exprs, p.parseExpr(0))
+		token := p.consume(lexer.STRING_MIDDLE, lexer.STRING_TAIL)
+		parts = append(parts, token.Value)
+		if token.Type == lexer.STRING_TAIL {
+			break
+		}
+	}
+	return ast.InterpolatedStringExpr{
+		Parts: parts,
+		Exprs: exprs,
+	}
+}
+
 func (p *parser) parseFuncCallExpr(left ast.Expr) ast.FuncCallExpr {
 	args := []ast.Expr{}
 	for p.peek().Type != lexer.CLOSE_PAREN {
-		args = append(args, p.parseExpr(0))
+		if p.peek().Type == lexer.IDENTIFIER && p.lookahead(1).Type == lexer.COLON {
+			name := p.consume(lexer.IDENTIFIER).Value
+			p.consume(lexer.COLON)
+			args = append(args, ast.NamedArgExpr{
+				Name:  name,
+				Value: p.parseExpr(0),
+			})
+		} else {
+			arg := p.parseExpr(0)
+			if p.peek().Type == lexer.ELLIPSIS {
+				p.consume(lexer.ELLIPSIS)
+				arg = ast.SpreadExpr{
+					Expr: arg,
+				}
+			}
+			args = append(args, arg)
+		}
 		if p.peek().Type == lexer.COMMA {
 			p.consume(lexer.COMMA)
 		}
@@ -381,19 +740,33 @@ func (p *parser) parseFuncCallExpr(left ast.Expr) ast.FuncCallExpr {
 }
 
 func (p *parser) parseStructLiteralExpr(left ast.Expr) ast.StructLiteralExpr {
+	var base ast.Expr
 	members := []ast.MemberAssignExpr{}
 	for p.peek().Type != lexer.CLOSE_CURLY {
-		memberName := p.consume(lexer.IDENTIFIER).Value
-		p.consume(lexer.COLON)
-		members = append(members, ast.MemberAssignExpr{
-			Name:  memberName,
-			Value: p.parseExpr(0),
-		})
-		p.consume(lexer.COMMA)
+		if p.peek().Type == lexer.DOT_DOT {
+			p.consume(lexer.DOT_DOT)
+			if base != nil {
+				panic("Struct literal can only be updated from one base value\n")
+			}
+			base = p.parseExpr(0)
+		} else {
+			memberName := p.consume(lexer.IDENTIFIER).Value
+			p.consume(lexer.COLON)
+			members = append(members, ast.MemberAssignExpr{
+				Name:  memberName,
+				Value: p.parseExpr(0),
+			})
+		}
+		if p.peek().Type == lexer.COMMA {
+			p.consume(lexer.COMMA)
+		} else {
+			break
+		}
 	}
 	p.consume(lexer.CLOSE_CURLY)
 	return ast.StructLiteralExpr{
 		Struct:  left,
+		Base:    base,
 		Members: members,
 	}
 }
@@ -407,12 +780,57 @@ func (p *parser) parseStructMemberExpr(left ast.Expr) ast.StructMemberExpr {
 	}
 }
 
-func (p *parser) parseArrayIndexExpr(left ast.Expr) ast.ArrayIndexExpr {
-	indexExpr := p.parseExpr(0)
+func (p *parser) parseOptionalMemberExpr(left ast.Expr) ast.OptionalMemberExpr {
+	return ast.OptionalMemberExpr{
+		Struct: left,
+		Member: ast.IdentExpr{
+			Value: p.consume(lexer.IDENTIFIER).Value,
+		},
+	}
+}
+
+func (p *parser) parseMapLiteralExpr() ast.MapLiteralExpr {
+	entries := []ast.MapEntry{}
+	for p.peek().Type != lexer.CLOSE_CURLY {
+		key := p.parseExpr(0)
+		p.consume(lexer.COLON)
+		value := p.parseExpr(0)
+		entries = append(entries, ast.MapEntry{
+			Key:   key,
+			Value: value,
+		})
+		if p.peek().Type != lexer.CLOSE_CURLY {
+			p.consume(lexer.COMMA)
+		}
+	}
+	p.consume(lexer.CLOSE_CURLY)
+	return ast.MapLiteralExpr{
+		Entries: entries,
+	}
+}
+
+func (p *parser) parseArrayIndexExpr(left ast.Expr) ast.Expr {
+	var indexExpr ast.Expr
+	if p.peek().Type != lexer.COLON {
+		indexExpr = p.parseExpr(0)
+	}
+	if p.peek().Type != lexer.COLON {
+		p.consume(lexer.CLOSE_BRACKET)
+		return ast.ArrayIndexExpr{
+			Array: left,
+			Index: indexExpr,
+		}
+	}
+	p.consume(lexer.COLON)
+	var endExpr ast.Expr
+	if p.peek().Type != lexer.CLOSE_BRACKET {
+		endExpr = p.parseExpr(0)
+	}
 	p.consume(lexer.CLOSE_BRACKET)
-	return ast.ArrayIndexExpr{
+	return ast.SliceExpr{
 		Array: left,
-		Index: indexExpr,
+		Start: indexExpr,
+		End:   endExpr,
 	}
 }
 
@@ -422,11 +840,57 @@ func (p *parser) parseReturnStmt() ast.ReturnStmt {
 		p.consume(lexer.SEMI_COLON)
 		return ast.ReturnStmt{Expr: nil}
 	}
+	expr := p.parseExpr(0)
+	if p.peek().Type == lexer.COMMA {
+		elems := []ast.Expr{expr}
+		for p.peek().Type == lexer.COMMA {
+			p.consume(lexer.COMMA)
+			elems = append(elems, p.parseExpr(0))
+		}
+		expr = ast.TupleExpr{
+			Elems: elems,
+		}
+	}
+	p.consume(lexer.SEMI_COLON)
 	return ast.ReturnStmt{
+		Expr: expr,
+	}
+}
+
+func (p *parser) parseDeferStmt() ast.DeferStmt {
+	p.consume(lexer.DEFER)
+	return ast.DeferStmt{
 		Expr: p.parseExpressionStmt().(ast.ExpressionStmt).Expr,
 	}
 }
 
+func (p *parser) parseStaticAssertStmt() ast.StaticAssertStmt {
+	p.consume(lexer.STATIC_ASSERT)
+	p.consume(lexer.OPEN_PAREN)
+	cond := p.parseExpr(0)
+	p.consume(lexer.COMMA)
+	message := p.parseExpr(0)
+	p.consume(lexer.CLOSE_PAREN)
+	p.consume(lexer.SEMI_COLON)
+	return ast.StaticAssertStmt{
+		Cond:    cond,
+		Message: message,
+	}
+}
+
+func (p *parser) parseAssertStmt() ast.AssertStmt {
+	location := p.location()
+	p.consume(lexer.ASSERT)
+	p.consume(lexer.OPEN_PAREN)
+	cond := p.parseExpr(0)
+	p.consume(lexer.CLOSE_PAREN)
+	p.consume(lexer.SEMI_COLON)
+	return ast.AssertStmt{
+		Cond:     cond,
+		Location: location,
+	}
+}
+
 func (p *parser) parseExpressionStmt() ast.Stmt {
 	expr := p.parseExpr(0)
 	p.consume(lexer.SEMI_COLON)
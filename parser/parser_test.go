@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"reflect"
+	"testing"
+)
+
+func TestParseFragments(t *testing.T) {
+	expr := ParseExpr(lexer.Tokenize("a[1] + b.c"))
+	want := ast.BinaryExpr{
+		Lhs: ast.ArrayIndexExpr{
+			Array: ast.IdentExpr{Value: "a"},
+			Index: ast.NumberLiteralExpr{Value: "1"},
+		},
+		Operator: lexer.Token{Type: lexer.PLUS, Value: "+"},
+		Rhs: ast.StructMemberExpr{
+			Struct: ast.IdentExpr{Value: "b"},
+			Member: ast.IdentExpr{Value: "c"},
+		},
+	}
+	if !reflect.DeepEqual(expr, want) {
+		t.Fatalf("unexpected expression %+v", expr)
+	}
+
+	if typ := ParseType(lexer.Tokenize("map[string](i32[])")); !reflect.DeepEqual(typ, ast.MapType{
+		KeyType:   ast.NamedType{TypeName: "string"},
+		ValueType: ast.ArrayType{UnderlyingType: ast.NamedType{TypeName: "i32"}},
+	}) {
+		t.Fatalf("unexpected type %+v", typ)
+	}
+
+	if stmt := ParseStmt(lexer.Tokenize("return x;")); !reflect.DeepEqual(stmt, ast.ReturnStmt{
+		Expr: ast.IdentExpr{Value: "x"},
+	}) {
+		t.Fatalf("unexpected statement %+v", stmt)
+	}
+}
+
+func TestParseFragmentsRequireFullInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func()
+	}{
+		{"expression", func() { ParseExpr(lexer.Tokenize("a + b; c")) }},
+		{"type", func() { ParseType(lexer.Tokenize("i32 x")) }},
+		{"statement", func() { ParseStmt(lexer.Tokenize("let x = 1; let y = 2;")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected trailing input to be rejected")
+				}
+			}()
+			tt.parse()
+		})
+	}
+}
+
+func TestParenthesizedTypeIsNotATuple(t *testing.T) {
+	if typ := ParseType(lexer.Tokenize("(i32)")); !reflect.DeepEqual(typ, ast.NamedType{TypeName: "i32"}) {
+		t.Fatalf("unexpected type %+v", typ)
+	}
+	if typ := ParseType(lexer.Tokenize("(i32, bool)")); !reflect.DeepEqual(typ, ast.TupleType{
+		ElemTypes: []ast.Type{ast.NamedType{TypeName: "i32"}, ast.NamedType{TypeName: "bool"}},
+	}) {
+		t.Fatalf("unexpected type %+v", typ)
+	}
+}
+
+func TestMapTypeSuffixes(t *testing.T) {
+	mapType := ast.MapType{
+		KeyType:   ast.NamedType{TypeName: "string"},
+		ValueType: ast.NamedType{TypeName: "i32"},
+	}
+	if typ := ParseType(lexer.Tokenize("map[string]i32[]")); !reflect.DeepEqual(typ, ast.ArrayType{UnderlyingType: mapType}) {
+		t.Fatalf("unexpected type %+v", typ)
+	}
+	if typ := ParseType(lexer.Tokenize("map[string]i32?")); !reflect.DeepEqual(typ, ast.OptionalType{UnderlyingType: mapType}) {
+		t.Fatalf("unexpected type %+v", typ)
+	}
+}
+
+func TestQuestionDotAfterCall(t *testing.T) {
+	call := ast.FuncCallExpr{
+		Func: ast.IdentExpr{Value: "get"},
+		Args: []ast.Expr{},
+	}
+	tests := []struct {
+		src  string
+		want ast.Expr
+	}{
+		{"get()?.x", ast.OptionalMemberExpr{
+			Struct: call,
+			Member: ast.IdentExpr{Value: "x"},
+		}},
+		{"get()? .x", ast.StructMemberExpr{
+			Struct: ast.TryExpr{Expr: call},
+			Member: ast.IdentExpr{Value: "x"},
+		}},
+		{"get()?.m()", ast.FuncCallExpr{
+			Func: ast.OptionalMemberExpr{
+				Struct: call,
+				Member: ast.IdentExpr{Value: "m"},
+			},
+			Args: []ast.Expr{},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if expr := ParseExpr(lexer.Tokenize(tt.src)); !reflect.DeepEqual(expr, tt.want) {
+				t.Fatalf("unexpected expression %+v", expr)
+			}
+		})
+	}
+}
+
+func TestSliceBounds(t *testing.T) {
+	xs := ast.IdentExpr{Value: "xs"}
+	n := ast.IdentExpr{Value: "n"}
+	tests := []struct {
+		src  string
+		want ast.Expr
+	}{
+		{"xs[n]", ast.ArrayIndexExpr{Array: xs, Index: n}},
+		{"xs[1:n]", ast.SliceExpr{Array: xs, Start: ast.NumberLiteralExpr{Value: "1"}, End: n}},
+		{"xs[:n]", ast.SliceExpr{Array: xs, End: n}},
+		{"xs[n:]", ast.SliceExpr{Array: xs, Start: n}},
+		{"xs[:]", ast.SliceExpr{Array: xs}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			if expr := ParseExpr(lexer.Tokenize(tt.src)); !reflect.DeepEqual(expr, tt.want) {
+				t.Fatalf("unexpected expression %+v", expr)
+			}
+		})
+	}
+}
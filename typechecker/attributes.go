@@ -0,0 +1,126 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"slices"
+	"strings"
+)
+
+type AttributeTarget int
+
+const (
+	FuncTarget AttributeTarget = iota
+	StructTarget
+	StructMemberTarget
+)
+
+func (t AttributeTarget) String() string {
+	switch t {
+	case FuncTarget:
+		return "function"
+	case StructTarget:
+		return "struct"
+	case StructMemberTarget:
+		return "struct member"
+	default:
+		return fmt.Sprintf("unknown(%d)", t)
+	}
+}
+
+type AttributeSpec struct {
+	Targets      []AttributeTarget
+	ParamTypes   []string // primitive type names of the literal arguments
+	RequiredArgs int
+}
+
+var KnownAttributes = map[string]AttributeSpec{
+	"inline": {
+		Targets: []AttributeTarget{FuncTarget},
+	},
+	"extern": {
+		Targets:    []AttributeTarget{FuncTarget},
+		ParamTypes: []string{"string"},
+	},
+	"deprecated": {
+		Targets:    []AttributeTarget{FuncTarget, StructTarget, StructMemberTarget},
+		ParamTypes: []string{"string"},
+	},
+}
+
+func (tc *TypeChecker) CheckAttributes(attributes []ast.Attribute, target AttributeTarget) {
+	seen := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		spec, ok := KnownAttributes[attribute.Name]
+		if !ok {
+			tc.Err(fmt.Sprintf("unknown attribute @%s", attribute.Name))
+			continue
+		}
+		if seen[attribute.Name] {
+			tc.Err(fmt.Sprintf("duplicate attribute @%s", attribute.Name))
+			continue
+		}
+		seen[attribute.Name] = true
+		if !slices.Contains(spec.Targets, target) {
+			tc.Err(fmt.Sprintf("attribute @%s cannot be applied to a %s", attribute.Name, target))
+			continue
+		}
+		if len(attribute.Args) < spec.RequiredArgs || len(attribute.Args) > len(spec.ParamTypes) {
+			tc.Err(fmt.Sprintf("wrong number of arguments for attribute @%s, expected %d to %d, found %d", attribute.Name, spec.RequiredArgs, len(spec.ParamTypes), len(attribute.Args)))
+			continue
+		}
+		for i, arg := range attribute.Args {
+			switch arg.(type) {
+			case ast.StringLiteralExpr, ast.NumberLiteralExpr, ast.BoolLiteralExpr:
+			default:
+				tc.Err(fmt.Sprintf("argument %d of attribute @%s must be a literal", i+1, attribute.Name))
+				continue
+			}
+			if argType := tc.InferType(arg); !IsPrimitive(argType, spec.ParamTypes[i]) {
+				tc.Err(fmt.Sprintf("argument %d of attribute @%s type mismatch: expected %s, found %s", i+1, attribute.Name, spec.ParamTypes[i], argType))
+			}
+		}
+	}
+}
+
+func FindAttribute(attributes []ast.Attribute, name string) (ast.Attribute, bool) {
+	for _, attribute := range attributes {
+		if attribute.Name == name {
+			return attribute, true
+		}
+	}
+	return ast.Attribute{}, false
+}
+
+func DeprecationMessage(attributes []ast.Attribute) (string, bool) {
+	attribute, ok := FindAttribute(attributes, "deprecated")
+	if !ok {
+		return "", false
+	}
+	if len(attribute.Args) > 0 {
+		if msg, ok := attribute.Args[0].(ast.StringLiteralExpr); ok {
+			return strings.Trim(msg.Value, "\""), true
+		}
+	}
+	return "", true
+}
+
+func (tc *TypeChecker) WarnDeprecatedStruct(structType StructType) {
+	if structType.Deprecated {
+		tc.WarnDeprecated("struct", structType.Name, structType.DeprecatedMsg)
+	}
+}
+
+func (tc *TypeChecker) WarnDeprecatedMember(structType StructType, member string) {
+	if msg, ok := structType.MemberDeprecation(member); ok {
+		tc.WarnDeprecated("member", fmt.Sprintf("%s.%s", structType.Name, member), msg)
+	}
+}
+
+func (tc *TypeChecker) WarnDeprecated(kind string, name string, msg string) {
+	if msg == "" {
+		tc.Warn(fmt.Sprintf("use of deprecated %s %s", kind, name))
+		return
+	}
+	tc.Warn(fmt.Sprintf("use of deprecated %s %s: %s", kind, name, msg))
+}
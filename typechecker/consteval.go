@@ -0,0 +1,241 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EvalConstExpr evaluates an expression made of literals and operators at
+// compile time. The result is an int64, float64, bool or string. Integer
+// literals are typed i32, so integer results that do not fit in an i32 are
+// reported as overflows rather than computed in a wider type.
+func EvalConstExpr(expr ast.Expr) (any, error) {
+	switch e := expr.(type) {
+	case ast.NumberLiteralExpr:
+		if strings.Contains(e.Value, ".") {
+			return strconv.ParseFloat(e.Value, 64)
+		}
+		n, err := strconv.ParseInt(e.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("constant %s overflows i32", e.Value)
+		}
+		return checkConstInt(n)
+	case ast.StringLiteralExpr:
+		return e.Value[1 : len(e.Value)-1], nil
+	case ast.BoolLiteralExpr:
+		return e.Value, nil
+	case ast.GroupExpr:
+		return EvalConstExpr(e.Expr)
+	case ast.UnaryExpr:
+		operand, err := EvalConstExpr(e.Rhs)
+		if err != nil {
+			return nil, err
+		}
+		return evalConstUnary(e.Operator, operand)
+	case ast.BinaryExpr:
+		lhs, err := EvalConstExpr(e.Lhs)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := EvalConstExpr(e.Rhs)
+		if err != nil {
+			return nil, err
+		}
+		return evalConstBinary(e.Operator, lhs, rhs)
+	case ast.IfExpr:
+		cond, err := EvalConstExpr(e.Cond)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := cond.(bool); !ok {
+			return nil, fmt.Errorf("if expression condition is not a bool: %v", cond)
+		} else if b {
+			return EvalConstExpr(e.Then)
+		}
+		return EvalConstExpr(e.Else)
+	case ast.IdentExpr:
+		return nil, fmt.Errorf("%s is not a constant", e.Value)
+	default:
+		return nil, fmt.Errorf("%T is not a constant expression", expr)
+	}
+}
+
+func evalConstUnary(operator lexer.Token, operand any) (any, error) {
+	switch v := operand.(type) {
+	case int64:
+		switch operator.Type {
+		case lexer.PLUS:
+			return v, nil
+		case lexer.DASH:
+			return checkConstInt(-v)
+		}
+	case float64:
+		switch operator.Type {
+		case lexer.PLUS:
+			return v, nil
+		case lexer.DASH:
+			return -v, nil
+		}
+	case bool:
+		if operator.Type == lexer.NOT {
+			return !v, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid constant operand for %s: %v", operator.Value, operand)
+}
+
+func evalConstBinary(operator lexer.Token, lhs any, rhs any) (any, error) {
+	if l, ok := lhs.(int64); ok {
+		if r, ok := rhs.(float64); ok {
+			return evalConstBinary(operator, float64(l), r)
+		}
+	}
+	if r, ok := rhs.(int64); ok {
+		if l, ok := lhs.(float64); ok {
+			return evalConstBinary(operator, l, float64(r))
+		}
+	}
+	switch l := lhs.(type) {
+	case int64:
+		if r, ok := rhs.(int64); ok {
+			return evalConstInts(operator, l, r)
+		}
+	case float64:
+		if r, ok := rhs.(float64); ok {
+			return evalConstFloats(operator, l, r)
+		}
+	case string:
+		if r, ok := rhs.(string); ok {
+			return evalConstStrings(operator, l, r)
+		}
+	case bool:
+		if r, ok := rhs.(bool); ok {
+			return evalConstBools(operator, l, r)
+		}
+	}
+	return nil, fmt.Errorf("invalid constant operands for %s: %v and %v", operator.Value, lhs, rhs)
+}
+
+// Operands are within the i32 range, so the int64 arithmetic itself cannot
+// overflow before the result is checked.
+func evalConstInts(operator lexer.Token, l int64, r int64) (any, error) {
+	switch operator.Type {
+	case lexer.PLUS:
+		return checkConstInt(l + r)
+	case lexer.DASH:
+		return checkConstInt(l - r)
+	case lexer.STAR:
+		return checkConstInt(l * r)
+	case lexer.SLASH, lexer.PERCENT:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero in constant expression")
+		}
+		if operator.Type == lexer.SLASH {
+			return checkConstInt(l / r)
+		}
+		return l % r, nil
+	}
+	return evalConstComparison(operator, l, r)
+}
+
+func checkConstInt(n int64) (any, error) {
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return nil, fmt.Errorf("constant %d overflows i32", n)
+	}
+	return n, nil
+}
+
+func evalConstFloats(operator lexer.Token, l float64, r float64) (any, error) {
+	switch operator.Type {
+	case lexer.PLUS:
+		return l + r, nil
+	case lexer.DASH:
+		return l - r, nil
+	case lexer.STAR:
+		return l * r, nil
+	case lexer.SLASH:
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero in constant expression")
+		}
+		return l / r, nil
+	}
+	return evalConstComparison(operator, l, r)
+}
+
+func evalConstStrings(operator lexer.Token, l string, r string) (any, error) {
+	if operator.Type == lexer.PLUS {
+		return l + r, nil
+	}
+	return evalConstComparison(operator, l, r)
+}
+
+func evalConstBools(operator lexer.Token, l bool, r bool) (any, error) {
+	switch operator.Type {
+	case lexer.AND:
+		return l && r, nil
+	case lexer.OR:
+		return l || r, nil
+	case lexer.EQUALS:
+		return l == r, nil
+	case lexer.NOT_EQUALS:
+		return l != r, nil
+	}
+	return nil, fmt.Errorf("invalid constant operands for %s: %v and %v", operator.Value, l, r)
+}
+
+func evalConstComparison[T int64 | float64 | string](operator lexer.Token, l T, r T) (any, error) {
+	switch operator.Type {
+	case lexer.EQUALS:
+		return l == r, nil
+	case lexer.NOT_EQUALS:
+		return l != r, nil
+	case lexer.LESS:
+		return l < r, nil
+	case lexer.LESS_EQUALS:
+		return l <= r, nil
+	case lexer.GREATER:
+		return l > r, nil
+	case lexer.GREATER_EQUALS:
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("invalid constant operands for %s: %v and %v", operator.Value, l, r)
+}
+
+func (tc *TypeChecker) CheckStaticAssertStmt(stmt ast.StaticAssertStmt) {
+	message, ok := stmt.Message.(ast.StringLiteralExpr)
+	if !ok {
+		tc.Err("static_assert message must be a string literal")
+		return
+	}
+	condType := tc.InferType(stmt.Cond)
+	if condType == nil {
+		return
+	}
+	if !IsPrimitive(condType, "bool") {
+		tc.Err(fmt.Sprintf("static_assert condition must be bool, found %s", condType))
+		return
+	}
+	cond, err := EvalConstExpr(stmt.Cond)
+	if err != nil {
+		tc.Err(fmt.Sprintf("static_assert condition is not a constant expression: %s", err))
+		return
+	}
+	if cond != true {
+		tc.Err(fmt.Sprintf("static assertion failed: %s", message.Value[1:len(message.Value)-1]))
+	}
+}
+
+func (tc *TypeChecker) CheckAssertStmt(stmt ast.AssertStmt) {
+	if tc.env.currentFuncReturnType == nil {
+		tc.Err("assert statement outside of function")
+		return
+	}
+	condType := tc.InferType(stmt.Cond)
+	if condType != nil && !IsPrimitive(condType, "bool") {
+		tc.Err(fmt.Sprintf("assert condition must be bool, found %s", condType))
+	}
+}
@@ -0,0 +1,78 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+const embeddingDecls = `
+struct Inner {
+    x: i32,
+    y: i32,
+}
+
+struct Middle {
+    Inner,
+    label: string,
+}
+
+struct Other {
+    x: bool,
+}
+`
+
+func TestEmbedding(t *testing.T) {
+	expectNoErrors(t, embeddingDecls+`
+struct Shadowing {
+    Middle,
+    x: string,
+}
+
+struct Shallow {
+    Middle,
+    Other,
+}
+
+struct Outer {
+    struct Inner {
+        z: i32,
+    }
+
+    inner: Inner,
+}
+
+func main(): void {
+    let inner: Inner = Inner{ x: 1, y: 2 };
+    let middle: Middle = Middle{ Inner: inner, label: "m" };
+    let s: Shadowing = Shadowing{ Middle: middle, x: "own" };
+    let own: string = s.x;
+    let promoted: i32 = s.y;
+    let embedded: Inner = s.Middle.Inner;
+    let shallow: Shallow = Shallow{ Middle: middle, Other: Other{ x: true } };
+    let nearest: bool = shallow.x;
+    let deeper: i32 = shallow.y;
+    let nested: Outer.Inner = Outer.Inner{ z: 3 };
+    let outer: Outer = Outer{ inner: nested };
+    let global: Inner = Inner{ x: outer.inner.z, y: 0 };
+    let z: i32 = outer.inner.z;
+}
+`)
+	tests := []struct {
+		name  string
+		decls string
+		body  string
+		want  string
+	}{
+		{"ambiguous promoted member", "struct Both {\n    Inner,\n    Other,\n}\n", "", "ambiguous member x in struct Both, promoted from Inner and Other"},
+		{"ambiguous member access", "struct Both {\n    Inner,\n    Other,\n}\n", "let b: Both;\n    let n = b.x;", "ambiguous member x of struct Both, promoted from Inner and Other"},
+		{"unknown nested struct", "struct Outer {\n    struct Inner {\n        z: i32,\n    }\n}\n", "let o: Outer.Missing;", "undefined type: Outer.Missing"},
+		{"nested struct needs qualification", "struct Outer {\n    struct Nested {\n        z: i32,\n    }\n}\n", "let n: Nested;", "undefined type: Nested"},
+		{"shadowed member type", "struct Shadowing {\n    Middle,\n    x: string,\n}\n", "let s: Shadowing;\n    let n: i32 = s.x;", "variable n declared as i32 but initialized with string"},
+		{"unresolved member type", "struct Broken {\n    inner: Missing,\n}\n", "let b = Broken{ inner: 1 };", "undefined type: Missing"},
+		{"unknown member", "", "let m: Middle;\n    let n: i32 = m.z;", "z is not a member of struct Middle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, embeddingDecls+tt.decls+"\nfunc main(): void {\n    "+tt.body+"\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"strings"
+)
+
+type ExternFunc struct {
+	Name   string
+	Symbol string
+	Type   FuncType
+}
+
+func IsFFISafe(t Type) bool {
+	switch t := Underlying(t).(type) {
+	case PrimitiveType:
+		return t.Name != "void"
+	case StructType:
+		for _, memberType := range t.Members {
+			if memberType == nil || !IsFFISafe(memberType) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Reports @extern on a function with a body, whose symbol name would otherwise
+// be silently ignored.
+func (tc *TypeChecker) CheckExternAttribute(stmt ast.FuncDeclStmt) {
+	if _, ok := FindAttribute(stmt.Attributes, "extern"); ok && !stmt.Extern {
+		tc.Err(fmt.Sprintf("attribute @extern on function %s requires an extern declaration", stmt.Name))
+	}
+}
+
+func (tc *TypeChecker) CheckExternFuncDecl(stmt ast.FuncDeclStmt, funcType FuncType) {
+	if tc.env.currentFuncReturnType != nil {
+		tc.Err(fmt.Sprintf("extern function %s must be declared at the top level", stmt.Name))
+		return
+	}
+	for i, paramType := range funcType.ParamTypes {
+		// The variadic arguments cross the boundary as a pointer to their
+		// elements followed by their count, so only the elements need to be
+		// FFI-safe.
+		if funcType.Variadic && i == len(funcType.ParamTypes)-1 {
+			paramType = paramType.(ArrayType).ElemType
+		}
+		if !IsFFISafe(paramType) {
+			tc.Err(fmt.Sprintf("parameter %s of extern function %s has type %s which cannot cross the FFI boundary", stmt.Parameters[i].Name, stmt.Name, paramType))
+		}
+	}
+	if !IsPrimitive(funcType.ReturnType, "void") && !IsFFISafe(funcType.ReturnType) {
+		tc.Err(fmt.Sprintf("extern function %s returns %s which cannot cross the FFI boundary", stmt.Name, funcType.ReturnType))
+	}
+	symbol := stmt.Name
+	if attribute, ok := FindAttribute(stmt.Attributes, "extern"); ok && len(attribute.Args) > 0 {
+		if name, ok := attribute.Args[0].(ast.StringLiteralExpr); ok {
+			symbol = strings.Trim(name.Value, "\"")
+		}
+	}
+	tc.Externs = append(tc.Externs, ExternFunc{
+		Name:   stmt.Name,
+		Symbol: symbol,
+		Type:   funcType,
+	})
+}
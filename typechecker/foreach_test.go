@@ -0,0 +1,53 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+func TestForEach(t *testing.T) {
+	expectNoErrors(t, `
+func count(names: string[]): i32 {
+    let total: i32 = 0;
+    for (i, name in names) {
+        let last: i32 = i;
+        let s: string = name;
+        total = total + 1;
+    }
+    for (n in 0..total) {
+        let square: i32 = n * n;
+    }
+    return total;
+}
+
+func main(): void {
+}
+`)
+	expectNoErrors(t, `
+func main(argc: i32, argv: string[]): void {
+    for (arg in argv) {
+        let arg: i32 = 1;
+    }
+    for (arg in argv) {
+        let s: string = arg;
+    }
+}
+`)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"element after loop", "for (arg in argv) {\n    }\n    let s: string = arg;", "undefined variable: arg"},
+		{"index after loop", "for (i, arg in argv) {\n    }\n    let n: i32 = i;", "undefined variable: i"},
+		{"body declaration after loop", "for (arg in argv) {\n        let n: i32 = 1;\n    }\n    let m: i32 = n;", "undefined variable: n"},
+		{"integer", "for (x in argc) {\n    }", "cannot iterate over non-array type i32"},
+		{"string", "for (c in \"text\") {\n    }", "cannot iterate over non-array type string"},
+		{"index over range", "for (i, n in 0..argc) {\n    }", "cannot use an index variable when iterating over a range"},
+		{"mismatched range bounds", "for (n in 0..true) {\n    }", "invalid range bounds: i32 and bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "func main(argc: i32, argv: string[]): void {\n    "+tt.body+"\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+func TestIfExpr(t *testing.T) {
+	expectNoErrors(t, `
+func main(argc: i32, argv: string[]): void {
+    let y: i32 = if (argc > 5) 10 else 20;
+    let sign = if (argc < 0) -1 else if (argc == 0) 0 else 1;
+    let name: string? = if (argc > 1) argv[1] else none;
+    let other: string? = if (argc > 1) none else argv[0];
+    let value: string = if (name != none) name else "default";
+}
+`)
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"missing else", "if (argc > 1) 1", "if- expression requires an else branch"},
+		{"mismatched branches", "if (argc > 1) 1 else \"one\"", "if- expression branches have different types: i32 and string"},
+		{"mismatched nested branch", "if (argc > 1) 1 else if (argc > 0) true else 0", "if- expression branches have different types: bool and i32"},
+		{"both none", "if (argc > 1) none else none", "cannot infer type of variable x from none"},
+		{"non-boolean condition", "if (argc) 1 else 2", "if- expression condition does not evaluate to a boolean type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "func main(argc: i32, argv: string[]): void {\n    let x = "+tt.expr+";\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+func TestInferredVarDecl(t *testing.T) {
+	expectNoErrors(t, `
+struct Point {
+    x: i32,
+}
+
+func main(): void {
+    let n = 1;
+    let s = "text";
+    let p = Point{ x: n };
+    let m = { "a": 1 };
+    let sum: i32 = n + p.x + m["a"];
+}
+`)
+	tests := []struct {
+		name string
+		decl string
+		want string
+	}{
+		{"none", "let x = none;", "cannot infer type of variable x from none"},
+		{"empty map", "let m = {};", "cannot infer type of variable m from map[_]_"},
+		{"ok", "let r = ok(1);", "cannot infer type of variable r from Result<i32,_>"},
+		{"err", `let r = err("bad");`, "cannot infer type of variable r from Result<_,string>"},
+		{"void", "let v = nothing();", "cannot infer type of variable v from an expression of type void"},
+		{"type name", "let t = Point;", "cannot infer type of variable t from type name Point"},
+		{"no initializer", "let u;", "cannot infer type of variable u without an initializer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "struct Point {\n    x: i32,\n}\n\nfunc nothing(): void {\n}\n\nfunc main(): void {\n    "+tt.decl+"\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"slices"
+	"strings"
+)
+
+type InterfaceType struct {
+	Name    string
+	Methods map[string]FuncType
+}
+
+func (i InterfaceType) String() string {
+	return i.Name
+}
+
+func (i InterfaceType) Equals(other Type) bool {
+	if o, ok := other.(InterfaceType); ok {
+		return i.Name == o.Name
+	}
+	return false
+}
+
+func MethodSet(t Type) (map[string]FuncType, bool) {
+	switch t := t.(type) {
+	case StructType:
+		return t.MethodSet(), true
+	case InterfaceType:
+		return t.Methods, true
+	}
+	return nil, false
+}
+
+func MissingMethods(iface InterfaceType, t Type) []string {
+	methods, ok := MethodSet(t)
+	if !ok {
+		return []string{fmt.Sprintf("%s has no methods", t)}
+	}
+	names := make([]string, 0, len(iface.Methods))
+	for name := range iface.Methods {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	missing := []string{}
+	for _, name := range names {
+		expected := iface.Methods[name]
+		method, ok := methods[name]
+		switch {
+		case !ok:
+			missing = append(missing, fmt.Sprintf("missing method %s %s", name, expected))
+		case !method.Equals(expected):
+			missing = append(missing, fmt.Sprintf("method %s has type %s, expected %s", name, method, expected))
+		}
+	}
+	return missing
+}
+
+func ConformanceDetail(to Type, from Type) string {
+	iface, ok := to.(InterfaceType)
+	if !ok || from == nil {
+		return ""
+	}
+	missing := MissingMethods(iface, from)
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(": %s does not implement %s (%s)", from, iface, strings.Join(missing, "; "))
+}
+
+func (tc *TypeChecker) CheckInterfaceDeclStmt(stmt ast.InterfaceDeclStmt) {
+	if _, ok := tc.LookupTypeName(stmt.Name); ok {
+		tc.Err(fmt.Sprintf("redeclared type %s in the same scope", stmt.Name))
+		return
+	}
+	methods := make(map[string]FuncType, len(stmt.Methods))
+	for _, method := range stmt.Methods {
+		if _, ok := methods[method.Name]; ok {
+			tc.Err(fmt.Sprintf("duplicate method %s in interface %s", method.Name, stmt.Name))
+			continue
+		}
+		methodType, ok := tc.ResolveType(method.Type).(FuncType)
+		if !ok {
+			continue
+		}
+		methods[method.Name] = methodType
+	}
+	tc.env.DefineType(stmt.Name, InterfaceType{
+		Name:    stmt.Name,
+		Methods: methods,
+	})
+}
@@ -0,0 +1,136 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+)
+
+type MapType struct {
+	KeyType   Type
+	ValueType Type
+}
+
+func (m MapType) String() string {
+	return fmt.Sprintf("map[%s]%s", m.KeyType, m.ValueType)
+}
+
+func (m MapType) Equals(other Type) bool {
+	if o, ok := other.(MapType); ok {
+		return m.KeyType.Equals(o.KeyType) && m.ValueType.Equals(o.ValueType)
+	}
+	return false
+}
+
+// The type of an empty map literal, which takes its key and value types from
+// the map it is assigned to.
+type EmptyMapType struct{}
+
+func (m EmptyMapType) String() string {
+	return "map[_]_"
+}
+
+func (m EmptyMapType) Equals(other Type) bool {
+	_, ok := other.(EmptyMapType)
+	return ok
+}
+
+func IsHashable(t Type) bool {
+	switch t := Underlying(t).(type) {
+	case PrimitiveType:
+		return IsInteger(t) || t.Name == "string" || t.Name == "bool"
+	case TupleType:
+		for _, elemType := range t.ElemTypes {
+			if !IsHashable(elemType) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (tc *TypeChecker) ResolveMapType(astType ast.MapType) Type {
+	keyType := tc.ResolveType(astType.KeyType)
+	valueType := tc.ResolveType(astType.ValueType)
+	if keyType == nil || valueType == nil {
+		return nil
+	}
+	if !IsHashable(keyType) {
+		tc.Err(fmt.Sprintf("invalid map key type %s: type is not hashable", keyType))
+		return nil
+	}
+	if IsPrimitive(valueType, "void") {
+		tc.Err("map value type cannot be void")
+		return nil
+	}
+	return MapType{
+		KeyType:   keyType,
+		ValueType: valueType,
+	}
+}
+
+func (tc *TypeChecker) CheckMapLiteralExpr(expr ast.MapLiteralExpr) Type {
+	if len(expr.Entries) == 0 {
+		return EmptyMapType{}
+	}
+	var mapType MapType
+	seenKeys := make(map[string]bool)
+	for i, entry := range expr.Entries {
+		keyType := tc.InferType(entry.Key)
+		valueType := tc.InferType(entry.Value)
+		if keyType == nil || valueType == nil {
+			return nil
+		}
+		if i == 0 {
+			if !IsHashable(keyType) {
+				tc.Err(fmt.Sprintf("invalid map key type %s: type is not hashable", keyType))
+				return nil
+			}
+			if IsIncomplete(valueType) || IsPrimitive(valueType, "void") {
+				tc.Err(fmt.Sprintf("cannot infer map value type from %s", valueType))
+				return nil
+			}
+			mapType = MapType{
+				KeyType:   keyType,
+				ValueType: valueType,
+			}
+		}
+		if !Assignable(mapType.KeyType, keyType) {
+			tc.Err(fmt.Sprintf("map key %d type mismatch: expected %s, found %s", i+1, mapType.KeyType, keyType))
+		}
+		if !Assignable(mapType.ValueType, valueType) {
+			tc.Err(fmt.Sprintf("map value %d type mismatch: expected %s, found %s", i+1, mapType.ValueType, valueType))
+		}
+		if key, ok := literalKey(entry.Key); ok {
+			if seenKeys[key] {
+				tc.Err(fmt.Sprintf("duplicate key %s in map literal", key))
+			}
+			seenKeys[key] = true
+		}
+	}
+	return mapType
+}
+
+func literalKey(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case ast.StringLiteralExpr:
+		return e.Value, true
+	case ast.NumberLiteralExpr:
+		return e.Value, true
+	case ast.BoolLiteralExpr:
+		return fmt.Sprintf("%t", e.Value), true
+	}
+	return "", false
+}
+
+func (tc *TypeChecker) CheckMapIndexExpr(mapType MapType, expr ast.ArrayIndexExpr) Type {
+	keyType := tc.InferType(expr.Index)
+	if keyType == nil {
+		return nil
+	}
+	if !Assignable(mapType.KeyType, keyType) {
+		tc.Err(fmt.Sprintf("map key type mismatch: expected %s, found %s", mapType.KeyType, keyType))
+		return nil
+	}
+	return mapType.ValueType
+}
@@ -0,0 +1,101 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+const namedArgsDecls = `
+func area(width: i32, height: i32 = 1): i32 {
+    return width * height;
+}
+`
+
+func TestNamedArguments(t *testing.T) {
+	expectNoErrors(t, namedArgsDecls+`
+func main(): void {
+    let a: i32 = area(2, 3);
+    let b: i32 = area(4);
+    let c: i32 = area(height: 5, width: 2);
+    let d: i32 = area(2, height: 7);
+}
+`)
+	tests := []struct {
+		name string
+		call string
+		want string
+	}{
+		{"duplicate", "area(2, width: 3)", "duplicate argument for parameter width"},
+		{"unknown", "area(2, depth: 3)", "unknown parameter depth in named argument"},
+		{"missing", "area(height: 3)", "missing argument for parameter width"},
+		{"positional after named", "area(width: 2, 3)", "positional argument after named arguments"},
+		{"too many", "area(1, 2, 3)", "wrong number of arguments, expected 2, found 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, namedArgsDecls+"\nfunc main(): void {\n    let n: i32 = "+tt.call+";\n}\n", tt.want)
+		})
+	}
+}
+
+func TestParamDefaults(t *testing.T) {
+	expectNoErrors(t, `
+func f(a: i32 = 2 * 3, b: string = "x", c: i32? = none, d: bool = !false): void {
+}
+
+func scaled(x: i32, scale: f32 = 1.0, offset: f32 = -0.5 * 2.0): f32 {
+    return scale * f32(x) + offset;
+}
+
+func main(): void {
+    f();
+    let a: f32 = scaled(1);
+    let b: f32 = scaled(x: 1, scale: 2.0);
+}
+`)
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"call",
+			"func counter(): i32 {\n    return 1;\n}\nfunc f(x: i32 = counter()): void {\n}\nfunc main(): void {\n}\n",
+			"default value of parameter x must be a constant expression",
+		},
+		{
+			"local of the enclosing function",
+			"func main(): void {\n    let local: i32 = 1;\n    func inner(x: i32 = local): void {\n    }\n}\n",
+			"default value of parameter x must be a constant expression: local is not a constant",
+		},
+		{
+			"type mismatch",
+			"func f(x: i32 = \"one\"): void {\n}\nfunc main(): void {\n}\n",
+			"default value of parameter x type mismatch: expected i32, found string",
+		},
+		{
+			"integer default of a float parameter",
+			"func f(scale: f32 = 1): void {\n}\nfunc main(): void {\n}\n",
+			"default value of parameter scale type mismatch: expected f32, found i32",
+		},
+		{
+			"float default of an integer parameter",
+			"func f(x: i32 = 1.5): void {\n}\nfunc main(): void {\n}\n",
+			"default value of parameter x type mismatch: expected i32, found f32",
+		},
+		{
+			"required after default",
+			"func f(x: i32 = 1, y: i32): void {\n}\nfunc main(): void {\n}\n",
+			"parameter y of function f must have a default value, since it follows a parameter with one",
+		},
+		{
+			"duplicate parameter",
+			"func f(x: i32, x: i32): void {\n}\nfunc main(): void {\n}\n",
+			"duplicate parameter x in function f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, tt.src, tt.want)
+		})
+	}
+}
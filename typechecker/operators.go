@@ -0,0 +1,140 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+	"github.com/ruistola/compiler-proto/lexer"
+	"slices"
+	"strings"
+)
+
+var BinaryOperatorFuncs = map[lexer.TokenType]string{
+	lexer.PLUS:           "op_add",
+	lexer.DASH:           "op_sub",
+	lexer.STAR:           "op_mul",
+	lexer.SLASH:          "op_div",
+	lexer.PERCENT:        "op_mod",
+	lexer.EQUALS:         "op_eq",
+	lexer.NOT_EQUALS:     "op_eq",
+	lexer.LESS:           "op_lt",
+	lexer.LESS_EQUALS:    "op_le",
+	lexer.GREATER:        "op_gt",
+	lexer.GREATER_EQUALS: "op_ge",
+}
+
+var UnaryOperatorFuncs = map[lexer.TokenType]string{
+	lexer.DASH: "op_neg",
+	lexer.NOT:  "op_not",
+}
+
+func IsOperatorFuncName(name string) bool {
+	_, ok := operatorFuncArity(name)
+	return ok
+}
+
+func operatorFuncArity(name string) (int, bool) {
+	for _, funcName := range BinaryOperatorFuncs {
+		if funcName == name {
+			return 2, true
+		}
+	}
+	for _, funcName := range UnaryOperatorFuncs {
+		if funcName == name {
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func isComparisonOperatorFunc(name string) bool {
+	return name == "op_eq" || name == "op_lt" || name == "op_le" || name == "op_gt" || name == "op_ge"
+}
+
+func hasStructOperand(operandTypes []Type) bool {
+	for _, operandType := range operandTypes {
+		if _, ok := Underlying(operandType).(StructType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func operatorSignature(name string, operandTypes []Type) string {
+	names := make([]string, len(operandTypes))
+	for i, operandType := range operandTypes {
+		names[i] = operandType.String()
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(names, ", "))
+}
+
+func (tc *TypeChecker) CheckOperatorFuncDecl(stmt ast.FuncDeclStmt, funcType FuncType) bool {
+	arity, _ := operatorFuncArity(stmt.Name)
+	if stmt.Extern {
+		tc.Err(fmt.Sprintf("operator function %s cannot be extern", stmt.Name))
+		return false
+	}
+	if funcType.Variadic {
+		tc.Err(fmt.Sprintf("operator function %s cannot be variadic", stmt.Name))
+		return false
+	}
+	if len(funcType.ParamTypes) != arity {
+		tc.Err(fmt.Sprintf("operator function %s must take %d parameters, found %d", stmt.Name, arity, len(funcType.ParamTypes)))
+		return false
+	}
+	if !hasStructOperand(funcType.ParamTypes) {
+		tc.Err(fmt.Sprintf("operator function %s must take at least one struct parameter", operatorSignature(stmt.Name, funcType.ParamTypes)))
+		return false
+	}
+	if isComparisonOperatorFunc(stmt.Name) && !IsPrimitive(funcType.ReturnType, "bool") {
+		tc.Err(fmt.Sprintf("operator function %s must return bool, found %s", stmt.Name, funcType.ReturnType))
+		return false
+	}
+	for _, overload := range tc.env.operators[stmt.Name] {
+		if slices.EqualFunc(overload.ParamTypes, funcType.ParamTypes, func(a, b Type) bool { return a.Equals(b) }) {
+			tc.Err(fmt.Sprintf("redeclared operator function %s in the same scope", operatorSignature(stmt.Name, funcType.ParamTypes)))
+			return false
+		}
+	}
+	tc.env.DefineOperator(stmt.Name, funcType)
+	return true
+}
+
+func (tc *TypeChecker) MatchOperatorOverloads(name string, operandTypes []Type) []FuncType {
+	exact := []FuncType{}
+	assignable := []FuncType{}
+	for _, overload := range tc.env.LookupOperators(name) {
+		if len(overload.ParamTypes) != len(operandTypes) {
+			continue
+		}
+		if slices.EqualFunc(overload.ParamTypes, operandTypes, func(a, b Type) bool { return a.Equals(b) }) {
+			exact = append(exact, overload)
+			continue
+		}
+		if slices.EqualFunc(overload.ParamTypes, operandTypes, Assignable) {
+			assignable = append(assignable, overload)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return assignable
+}
+
+func (tc *TypeChecker) CheckOperatorOverload(operator lexer.Token, name string, operandTypes ...Type) Type {
+	matches := tc.MatchOperatorOverloads(name, operandTypes)
+	switch len(matches) {
+	case 0:
+		tc.Err(fmt.Sprintf("no operator overload %s for %s", operatorSignature(name, operandTypes), operator.Value))
+		return nil
+	case 1:
+		return matches[0].ReturnType
+	default:
+		candidates := make([]string, len(matches))
+		for i, match := range matches {
+			candidates[i] = operatorSignature(name, match.ParamTypes)
+		}
+		slices.Sort(candidates)
+		tc.Err(fmt.Sprintf("ambiguous operator overload %s for %s, candidates: %s", operatorSignature(name, operandTypes), operator.Value, strings.Join(candidates, ", ")))
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+func TestOptionals(t *testing.T) {
+	expectNoErrors(t, `
+struct Point {
+    x: i32,
+}
+
+func find(n: i32): Point? {
+    if (n > 0) {
+        return Point{ x: n };
+    }
+    return none;
+}
+
+func main(): void {
+    let p: Point? = find(1);
+    let x: i32 = p?.x ?? 0;
+    let q: Point = p ?? Point{ x: 0 };
+    if (p != none) {
+        x = p.x;
+    }
+    if (p == none) {
+        return;
+    }
+    let y: i32 = p.x;
+    p = none;
+}
+`)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"member without check", "let y: i32 = p.x;", "cannot access member x of optional type Point? without ?. or a none check"},
+		{"optional to value", "let q: Point = p;", "variable q declared as Point but initialized with Point?"},
+		{"none to value", "let n: i32 = none;", "variable n declared as i32 but initialized with none"},
+		{"?? on non-optional", "let n: i32 = 1 ?? 2;", "left operand of ?? must be optional, found i32"},
+		{"?. on non-optional", "let n: i32 = 1;\n    let m = n?.x;", "?. used on non-optional type i32"},
+		{"narrowing ends at reassignment", "if (p != none) {\n        p = find(2);\n        let y: i32 = p.x;\n    }", "cannot access member x of optional type Point?"},
+		{"narrowing does not leak from the branch", "if (p != none) {\n    }\n    let y: i32 = p.x;", "cannot access member x of optional type Point?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "struct Point {\n    x: i32,\n}\n\nfunc find(n: i32): Point? {\n    return none;\n}\n\nfunc main(): void {\n    let p: Point? = find(1);\n    "+tt.body+"\n}\n", tt.want)
+		})
+	}
+}
+
+func TestNarrowingInLoops(t *testing.T) {
+	tests := []struct {
+		name string
+		loop string
+		want string
+	}{
+		{"for", "for (let i: i32 = 0; i < 3; i = i + 1) {\n            use(x + 1);\n            x = none;\n        }", "invalid operands for +: i32? and i32"},
+		{"for-each", "for (i in 0..3) {\n            use(x + 1);\n            x = none;\n        }", "invalid operands for +: i32? and i32"},
+		{"nested block", "for (i in 0..3) {\n            use(x + 1);\n            {\n                x = none;\n            }\n        }", "invalid operands for +: i32? and i32"},
+		{"iteration", "for (let i: i32 = 0; i < x; x = none) {\n        }", "invalid operands for <: i32 and i32?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "func use(n: i32): void {\n}\n\nfunc main(): void {\n    let x: i32? = 1;\n    if (x != none) {\n        "+tt.loop+"\n    }\n}\n", tt.want)
+		})
+	}
+	expectNoErrors(t, `
+func use(n: i32): void {
+}
+
+func main(): void {
+    let x: i32? = 1;
+    if (x != none) {
+        for (i in 0..3) {
+            use(x + 1);
+        }
+        use(x);
+    }
+    for (i in 0..3) {
+        if (x == none) {
+            return;
+        }
+        use(x + 1);
+        x = none;
+    }
+}
+`)
+}
@@ -0,0 +1,83 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+const resultDecls = `
+struct Point {
+    x: i32,
+
+    func moved(dx: i32): i32 {
+        return self.x + dx;
+    }
+}
+
+func get(x: i32): Result<Point, string> {
+    if (x < 0) {
+        return err("negative");
+    }
+    return ok(Point{ x: x });
+}
+
+func find(x: i32): Result<Point?, string> {
+    return ok(none);
+}
+`
+
+func TestResultMemberAccess(t *testing.T) {
+	expectNoErrors(t, resultDecls+`
+func sum(a: i32, b: i32): Result<i32, string> {
+    let x: i32 = get(a)?.x;
+    let moved: i32 = get(b)?.moved(1);
+    let y: i32 = get(b)? .x;
+    return ok(x + y + moved);
+}
+
+func main(): void {
+}
+`)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"outside a result function", "func f(): i32 {\n    return get(1)?.x;\n}", "? operator used in function returning i32, expected a result type"},
+		{"optional value", "func f(): Result<i32, string> {\n    return ok(find(1)?.x);\n}", "cannot access member x of optional type Point? without ?. or a none check"},
+		{"incompatible error", "func f(): Result<i32, i32> {\n    return ok(get(1)?.x);\n}", "? operator cannot propagate error string from function returning Result<i32,i32>"},
+		{"missing member", "func f(): Result<i32, string> {\n    return ok(get(1)?.y);\n}", "y is not a member of struct Point"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, resultDecls+tt.body+"\n\nfunc main(): void {\n}\n", tt.want)
+		})
+	}
+}
+
+func TestTry(t *testing.T) {
+	expectNoErrors(t, resultDecls+`
+func first(a: i32): Result<i32, string> {
+    let p: Point = get(a)?;
+    return ok(p.x);
+}
+
+func main(): void {
+}
+`)
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"outside a function", "let p: Point = get(1)?;\n\nfunc main(): void {\n}\n", "? operator used outside of function"},
+		{"in a void function", "func main(): void {\n    get(1)?;\n}\n", "? operator used in function returning void, expected a result type"},
+		{"in an optional function", "func f(): Point? {\n    return get(1)?;\n}\n\nfunc main(): void {\n}\n", "? operator used in function returning Point?, expected a result type"},
+		{"incompatible error", "func f(): Result<Point, i32> {\n    return ok(get(1)?);\n}\n\nfunc main(): void {\n}\n", "? operator cannot propagate error string from function returning Result<Point,i32>"},
+		{"non-result operand", "func f(): Result<i32, string> {\n    let n: i32 = 1;\n    return ok(n?);\n}\n\nfunc main(): void {\n}\n", "? operator applied to non-result type i32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, resultDecls+tt.src, tt.want)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+func TestSlicing(t *testing.T) {
+	expectNoErrors(t, `
+func main(argc: i32, argv: string[]): void {
+    let n: i32 = 2;
+    let middle: string[] = argv[1:3];
+    let head: string[] = argv[:n];
+    let tail: string[] = argv[n:];
+    let copy: string[] = argv[:];
+    let first: string = argv[0:1][0];
+    let greeting: string = "hello, world";
+    let hello: string = greeting[:5];
+    let world: string = greeting[7:];
+    let all: string = greeting[:][0:argc];
+}
+`)
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"float bound", "argv[f:]", "slice bound does not result in an integer type: f32"},
+		{"bool end bound", "argv[:true]", "slice bound does not result in an integer type: bool"},
+		{"string bounds", "\"text\"[\"a\":\"b\"]", "slice bound does not result in an integer type: string"},
+		{"integer", "argc[0:1]", "cannot slice non-array type i32"},
+		{"map", "m[0:1]", "cannot slice non-array type map[string]i32"},
+		{"array slice is an array", "argv[0:1] + 1", "invalid operands for +: string[] and i32"},
+		{"string slice is a string", "\"text\"[1:] + 1", "invalid operands for +: string and i32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "func main(argc: i32, argv: string[]): void {\n    let f: f32 = f32(1);\n    let m = { \"a\": 1 };\n    let x = "+tt.expr+";\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+const configDecl = `
+struct Config {
+    name: string,
+    verbose: bool = false,
+    level: i32 = 1 + 2,
+    parent: string? = none,
+    ratio: f32 = 0.5,
+}
+`
+
+func TestStructDefaults(t *testing.T) {
+	expectNoErrors(t, configDecl+`
+func main(argc: i32, argv: string[]): void {
+    let base: Config = Config{ name: "default" };
+    let loud: Config = Config{ ..base, verbose: true, level: 3 };
+    let renamed = Config{
+        ..loud,
+        name: argv[0],
+    };
+    let copy: Config = Config{ ..renamed };
+}
+`)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing member", "let c = Config{ verbose: true };", "struct member name is not assigned a value"},
+		{"unknown member", "let c = Config{ name: \"x\", quiet: true };", "quiet is not a member of struct Config"},
+		{"repeated member", "let c = Config{ name: \"x\", name: \"y\" };", "struct member name assigned multiple times"},
+		{"mismatched member", "let c = Config{ name: 1 };", "cannot assign i32 to string of struct member name"},
+		{"base of another type", "let c = Config{ ..argc, name: \"x\" };", "cannot update struct Config from a value of type i32"},
+		{"unknown member with base", "let base = Config{ name: \"x\" };\n    let c = Config{ ..base, quiet: true };", "quiet is not a member of struct Config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, configDecl+"\nfunc main(argc: i32, argv: string[]): void {\n    "+tt.body+"\n}\n", tt.want)
+		})
+	}
+}
+
+func TestStructMemberDefaultErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		member string
+		want   string
+	}{
+		{"mismatched type", "level: i32 = \"one\",", "cannot use string as default value of i32 for struct member Config.level"},
+		{"variable", "level: i32 = argc,", "undefined variable: argc"},
+		{"function call", "level: i32 = one(),", "default value of struct member Config.level must be a constant expression"},
+		{"overflow", "level: i32 = 2147483647 + 1,", "default value of struct member Config.level must be a constant expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, "func one(): i32 {\n    return 1;\n}\n\nstruct Config {\n    "+tt.member+"\n}\n\nfunc main(): void {\n}\n", tt.want)
+		})
+	}
+}
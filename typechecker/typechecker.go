@@ -5,6 +5,7 @@ import (
 	"github.com/ruistola/compiler-proto/ast"
 	"github.com/ruistola/compiler-proto/lexer"
 	"slices"
+	"strings"
 )
 
 type Type interface {
@@ -41,6 +42,17 @@ func IsNumeric(t Type) bool {
 	return false
 }
 
+func IsInteger(t Type) bool {
+	if p, ok := t.(PrimitiveType); ok {
+		return p.Name == "i8" || p.Name == "i32" || p.Name == "i64"
+	}
+	return false
+}
+
+func IsPrintable(t Type) bool {
+	return IsNumeric(t) || IsPrimitive(t, "bool") || IsPrimitive(t, "string")
+}
+
 type ArrayType struct {
 	ElemType Type
 }
@@ -59,6 +71,24 @@ func (a ArrayType) Equals(other Type) bool {
 type FuncType struct {
 	ReturnType Type
 	ParamTypes []Type
+	// The last parameter collects any remaining arguments into an array, and
+	// its entry in ParamTypes is that array type.
+	Variadic bool
+	// Only known for declared functions, and not part of the type identity:
+	// the names of the parameters, and how many parameters before the
+	// variadic one, if any, have default values.
+	ParamNames    []string
+	DefaultParams int
+	// Set by @deprecated on the declaration.
+	Deprecated    bool
+	DeprecatedMsg string
+}
+
+func (f FuncType) FixedParams() int {
+	if f.Variadic {
+		return len(f.ParamTypes) - 1
+	}
+	return len(f.ParamTypes)
 }
 
 func (f FuncType) String() string {
@@ -67,6 +97,10 @@ func (f FuncType) String() string {
 		if i > 0 {
 			params += ","
 		}
+		if f.Variadic && i == len(f.ParamTypes)-1 {
+			params += "..." + param.(ArrayType).ElemType.String()
+			continue
+		}
 		params += param.String()
 	}
 	return fmt.Sprintf("func(%s):%s", params, f.ReturnType)
@@ -74,7 +108,7 @@ func (f FuncType) String() string {
 
 func (f FuncType) Equals(other Type) bool {
 	o, ok := other.(FuncType)
-	if !ok || len(f.ParamTypes) != len(o.ParamTypes) {
+	if !ok || len(f.ParamTypes) != len(o.ParamTypes) || f.Variadic != o.Variadic {
 		return false
 	}
 	if !f.ReturnType.Equals(o.ReturnType) {
@@ -88,9 +122,194 @@ func (f FuncType) Equals(other Type) bool {
 	return true
 }
 
+type OptionalType struct {
+	ElemType Type
+}
+
+func (o OptionalType) String() string {
+	return fmt.Sprintf("%s?", o.ElemType)
+}
+
+func (o OptionalType) Equals(other Type) bool {
+	if other, ok := other.(OptionalType); ok {
+		return o.ElemType.Equals(other.ElemType)
+	}
+	return false
+}
+
+type NoneType struct{}
+
+func (n NoneType) String() string {
+	return "none"
+}
+
+func (n NoneType) Equals(other Type) bool {
+	_, ok := other.(NoneType)
+	return ok
+}
+
+type ResultType struct {
+	ValueType Type
+	ErrType   Type
+}
+
+// The members through which lowering passes inspect a result: whether it is an
+// error, its value and its error. The lexer cannot produce these names, so user
+// code cannot access them.
+const (
+	ResultIsErrMember = "$isErr"
+	ResultValueMember = "$value"
+	ResultErrMember   = "$err"
+)
+
+func (r ResultType) String() string {
+	return fmt.Sprintf("Result<%s,%s>", r.ValueType, r.ErrType)
+}
+
+func (r ResultType) Equals(other Type) bool {
+	if o, ok := other.(ResultType); ok {
+		return r.ValueType.Equals(o.ValueType) && r.ErrType.Equals(o.ErrType)
+	}
+	return false
+}
+
+type ResultOkType struct {
+	ValueType Type
+}
+
+func (r ResultOkType) String() string {
+	return fmt.Sprintf("Result<%s,_>", r.ValueType)
+}
+
+func (r ResultOkType) Equals(other Type) bool {
+	if o, ok := other.(ResultOkType); ok {
+		return r.ValueType.Equals(o.ValueType)
+	}
+	return false
+}
+
+type ResultErrType struct {
+	ErrType Type
+}
+
+func (r ResultErrType) String() string {
+	return fmt.Sprintf("Result<_,%s>", r.ErrType)
+}
+
+func (r ResultErrType) Equals(other Type) bool {
+	if o, ok := other.(ResultErrType); ok {
+		return r.ErrType.Equals(o.ErrType)
+	}
+	return false
+}
+
+func IsIncomplete(t Type) bool {
+	switch t.(type) {
+	case NoneType, ResultOkType, ResultErrType, EmptyMapType:
+		return true
+	}
+	return false
+}
+
+func Assignable(to Type, from Type) bool {
+	if to.Equals(from) {
+		return true
+	}
+	if i, ok := to.(InterfaceType); ok {
+		return len(MissingMethods(i, from)) == 0
+	}
+	if r, ok := to.(ResultType); ok {
+		switch f := from.(type) {
+		case ResultOkType:
+			return Assignable(r.ValueType, f.ValueType)
+		case ResultErrType:
+			return Assignable(r.ErrType, f.ErrType)
+		}
+		return false
+	}
+	if _, ok := to.(MapType); ok {
+		_, ok := from.(EmptyMapType)
+		return ok
+	}
+	if o, ok := to.(OptionalType); ok {
+		if _, ok := from.(NoneType); ok {
+			return true
+		}
+		return Assignable(o.ElemType, from)
+	}
+	return false
+}
+
+type TupleType struct {
+	ElemTypes []Type
+}
+
+func (t TupleType) String() string {
+	elems := ""
+	for i, elem := range t.ElemTypes {
+		if i > 0 {
+			elems += ","
+		}
+		elems += elem.String()
+	}
+	return fmt.Sprintf("(%s)", elems)
+}
+
+func (t TupleType) Equals(other Type) bool {
+	o, ok := other.(TupleType)
+	if !ok || len(t.ElemTypes) != len(o.ElemTypes) {
+		return false
+	}
+	for i, elem := range t.ElemTypes {
+		if !elem.Equals(o.ElemTypes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+type DistinctType struct {
+	Name           string
+	UnderlyingType Type
+}
+
+func (d DistinctType) String() string {
+	return d.Name
+}
+
+func (d DistinctType) Equals(other Type) bool {
+	if o, ok := other.(DistinctType); ok {
+		return d.Name == o.Name
+	}
+	return false
+}
+
+func Underlying(t Type) Type {
+	if d, ok := t.(DistinctType); ok {
+		return Underlying(d.UnderlyingType)
+	}
+	return t
+}
+
+// Reports whether t is a distinct type over a numeric type. Arithmetic and
+// ordering apply to two operands of the same such type, and arithmetic keeps
+// the distinct type, but mixing it with any other type needs a conversion.
+func IsDistinctNumeric(t Type) bool {
+	_, ok := t.(DistinctType)
+	return ok && IsNumeric(Underlying(t))
+}
+
 type StructType struct {
-	Name    string
-	Members map[string]Type
+	Name              string
+	Members           map[string]Type
+	Methods           map[string]FuncType
+	Embedded          []StructType
+	NestedTypes       map[string]StructType
+	DefaultedMembers  map[string]bool
+	DeprecatedMembers map[string]string
+	// Set by @deprecated on the declaration.
+	Deprecated    bool
+	DeprecatedMsg string
 }
 
 func (s StructType) String() string {
@@ -104,12 +323,82 @@ func (s StructType) Equals(other Type) bool {
 	return false
 }
 
+func (s StructType) LookupMember(name string) (Type, []string) {
+	if memberType, ok := s.Members[name]; ok {
+		return memberType, []string{s.Name}
+	}
+	level := s.Embedded
+	for len(level) > 0 {
+		var memberType Type
+		owners := []string{}
+		nextLevel := []StructType{}
+		for _, embedded := range level {
+			if t, ok := embedded.Members[name]; ok {
+				memberType = t
+				owners = append(owners, embedded.Name)
+			}
+			nextLevel = append(nextLevel, embedded.Embedded...)
+		}
+		if len(owners) > 0 {
+			return memberType, owners
+		}
+		level = nextLevel
+	}
+	return nil, nil
+}
+
+// Finds the deprecation message of a member or method, following promotion
+// through embedded structs the same way LookupMember and MethodSet do.
+func (s StructType) MemberDeprecation(name string) (string, bool) {
+	_, isMember := s.Members[name]
+	_, isMethod := s.Methods[name]
+	if isMember || isMethod {
+		msg, ok := s.DeprecatedMembers[name]
+		return msg, ok
+	}
+	for _, embedded := range s.Embedded {
+		if msg, ok := embedded.MemberDeprecation(name); ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+func (s StructType) MethodSet() map[string]FuncType {
+	methods := make(map[string]FuncType)
+	for _, embedded := range s.Embedded {
+		for name, method := range embedded.MethodSet() {
+			if _, ok := methods[name]; !ok {
+				methods[name] = method
+			}
+		}
+	}
+	for name, method := range s.Methods {
+		methods[name] = method
+	}
+	return methods
+}
+
+func (s StructType) MemberNames() []string {
+	names := []string{}
+	for name := range s.Members {
+		names = append(names, name)
+	}
+	for _, embedded := range s.Embedded {
+		names = append(names, embedded.MemberNames()...)
+	}
+	return names
+}
+
 type TypeEnv struct {
 	parent                *TypeEnv
 	vars                  map[string]Type
+	narrowed              map[string]Type
 	structTypes           map[string]StructType
+	types                 map[string]Type
 	funcs                 map[string]string
 	funcTypes             map[string]FuncType
+	operators             map[string][]FuncType
 	currentFuncReturnType Type
 }
 
@@ -117,9 +406,12 @@ func NewTypeEnv(parent *TypeEnv) *TypeEnv {
 	newTypeEnv := &TypeEnv{
 		parent:      parent,
 		vars:        make(map[string]Type),
+		narrowed:    make(map[string]Type),
 		structTypes: make(map[string]StructType),
+		types:       make(map[string]Type),
 		funcs:       make(map[string]string),
 		funcTypes:   make(map[string]FuncType),
+		operators:   make(map[string][]FuncType),
 	}
 	if parent != nil {
 		newTypeEnv.currentFuncReturnType = parent.currentFuncReturnType
@@ -129,9 +421,13 @@ func NewTypeEnv(parent *TypeEnv) *TypeEnv {
 
 func (env *TypeEnv) DefineVar(name string, varType Type) {
 	env.vars[name] = varType
+	delete(env.narrowed, name)
 }
 
 func (env *TypeEnv) LookupVarType(name string) (Type, bool) {
+	if narrowedType, ok := env.narrowed[name]; ok {
+		return narrowedType, true
+	}
 	if varType, ok := env.vars[name]; ok {
 		return varType, true
 	}
@@ -141,6 +437,30 @@ func (env *TypeEnv) LookupVarType(name string) (Type, bool) {
 	return nil, false
 }
 
+func (env *TypeEnv) LookupDeclaredVarType(name string) (Type, bool) {
+	if varType, ok := env.vars[name]; ok {
+		return varType, true
+	}
+	if env.parent != nil {
+		return env.parent.LookupDeclaredVarType(name)
+	}
+	return nil, false
+}
+
+func (env *TypeEnv) NarrowVar(name string, narrowedType Type) {
+	env.narrowed[name] = narrowedType
+}
+
+func (env *TypeEnv) WidenVar(name string) {
+	delete(env.narrowed, name)
+	if _, ok := env.vars[name]; ok {
+		return
+	}
+	if env.parent != nil {
+		env.parent.WidenVar(name)
+	}
+}
+
 func (env *TypeEnv) DefineStructType(name string, st StructType) {
 	env.structTypes[name] = st
 }
@@ -155,6 +475,20 @@ func (env *TypeEnv) LookupStructType(name string) (StructType, bool) {
 	return StructType{}, false
 }
 
+func (env *TypeEnv) DefineType(name string, t Type) {
+	env.types[name] = t
+}
+
+func (env *TypeEnv) LookupType(name string) (Type, bool) {
+	if t, ok := env.types[name]; ok {
+		return t, true
+	}
+	if env.parent != nil {
+		return env.parent.LookupType(name)
+	}
+	return nil, false
+}
+
 func (env *TypeEnv) DefineFunc(name string, funcTypeName string) {
 	env.funcs[name] = funcTypeName
 }
@@ -183,16 +517,31 @@ func (env *TypeEnv) LookupFuncType(name string) (FuncType, bool) {
 	return FuncType{}, false
 }
 
+func (env *TypeEnv) DefineOperator(name string, fn FuncType) {
+	env.operators[name] = append(env.operators[name], fn)
+}
+
+func (env *TypeEnv) LookupOperators(name string) []FuncType {
+	operators := env.operators[name]
+	if env.parent != nil {
+		operators = append(operators[:len(operators):len(operators)], env.parent.LookupOperators(name)...)
+	}
+	return operators
+}
+
 type TypeChecker struct {
 	Errors     []string
+	Warnings   []string
+	Externs    []ExternFunc
 	env        *TypeEnv
 	primitives map[string]Type
 }
 
 func NewTypeChecker() *TypeChecker {
 	return &TypeChecker{
-		Errors: []string{},
-		env:    NewTypeEnv(nil),
+		Errors:   []string{},
+		Warnings: []string{},
+		env:      NewTypeEnv(nil),
 		primitives: map[string]Type{
 			"void":   PrimitiveType{Name: "void"},
 			"bool":   PrimitiveType{Name: "bool"},
@@ -211,6 +560,11 @@ func (tc *TypeChecker) Err(msg string) {
 	tc.Errors = append(tc.Errors, coloredMsg)
 }
 
+func (tc *TypeChecker) Warn(msg string) {
+	coloredMsg := fmt.Sprintf("\033[33mWarning: %s\033[0m", msg)
+	tc.Warnings = append(tc.Warnings, coloredMsg)
+}
+
 func (tc *TypeChecker) ResolveType(astType ast.Type) Type {
 	switch t := astType.(type) {
 	case ast.NamedType:
@@ -218,8 +572,18 @@ func (tc *TypeChecker) ResolveType(astType ast.Type) Type {
 			return prim
 		}
 		if structType, ok := tc.env.LookupStructType(t.TypeName); ok {
+			tc.WarnDeprecatedStruct(structType)
 			return structType
 		}
+		if strings.Contains(t.TypeName, ".") {
+			if structType, ok := tc.LookupNestedStructType(t.TypeName); ok {
+				tc.WarnDeprecatedStruct(structType)
+				return structType
+			}
+		}
+		if declaredType, ok := tc.env.LookupType(t.TypeName); ok {
+			return declaredType
+		}
 		tc.Err(fmt.Sprintf("undefined type: %s", t.TypeName))
 		return nil
 	case ast.ArrayType:
@@ -228,6 +592,16 @@ func (tc *TypeChecker) ResolveType(astType ast.Type) Type {
 			return nil
 		}
 		return ArrayType{ElemType: elemType}
+	case ast.OptionalType:
+		elemType := tc.ResolveType(t.UnderlyingType)
+		if elemType == nil {
+			return nil
+		}
+		if IsPrimitive(elemType, "void") {
+			tc.Err("optional type cannot wrap void")
+			return nil
+		}
+		return OptionalType{ElemType: elemType}
 	case ast.FuncType:
 		paramTypes := []Type{}
 		for _, astParamType := range t.ParamTypes {
@@ -241,20 +615,71 @@ func (tc *TypeChecker) ResolveType(astType ast.Type) Type {
 		if returnType == nil {
 			return nil
 		}
+		if t.Variadic {
+			if len(paramTypes) != len(t.ParamTypes) {
+				return nil
+			}
+			last := len(paramTypes) - 1
+			paramTypes[last] = ArrayType{ElemType: paramTypes[last]}
+		}
 		return FuncType{
 			ReturnType: returnType,
 			ParamTypes: paramTypes,
+			Variadic:   t.Variadic,
+		}
+	case ast.MapType:
+		return tc.ResolveMapType(t)
+	case ast.ResultType:
+		valueType := tc.ResolveType(t.ValueType)
+		errType := tc.ResolveType(t.ErrType)
+		if valueType == nil || errType == nil {
+			return nil
+		}
+		if IsPrimitive(errType, "void") {
+			tc.Err("result error type cannot be void")
+			return nil
+		}
+		return ResultType{
+			ValueType: valueType,
+			ErrType:   errType,
+		}
+	case ast.TupleType:
+		elemTypes := make([]Type, 0, len(t.ElemTypes))
+		for _, astElemType := range t.ElemTypes {
+			elemType := tc.ResolveType(astElemType)
+			if elemType == nil {
+				return nil
+			}
+			if IsPrimitive(elemType, "void") {
+				tc.Err("tuple element cannot be of type void")
+				return nil
+			}
+			elemTypes = append(elemTypes, elemType)
 		}
+		return TupleType{ElemTypes: elemTypes}
 	default:
 		tc.Err(fmt.Sprintf("unknown type: %T", astType))
 		return nil
 	}
 }
 
-func Check(program ast.BlockStmt) []string {
+// The outcome of type checking a program. Externs lists the extern functions
+// the program declares, with the symbols a back end binds them to.
+type CheckResult struct {
+	Errors   []string
+	Warnings []string
+	Externs  []ExternFunc
+}
+
+func Check(program ast.BlockStmt) CheckResult {
 	tc := NewTypeChecker()
+	tc.ValidateProgram(program)
 	tc.CheckBlockStmt(program)
-	return tc.Errors
+	return CheckResult{
+		Errors:   tc.Errors,
+		Warnings: tc.Warnings,
+		Externs:  tc.Externs,
+	}
 }
 
 func (tc *TypeChecker) CheckBlockStmt(block ast.BlockStmt) {
@@ -272,16 +697,30 @@ func (tc *TypeChecker) CheckStmt(stmt ast.Stmt) {
 		tc.CheckBlockStmt(s)
 	case ast.VarDeclStmt:
 		tc.CheckVarDeclStmt(s)
+	case ast.TupleDeclStmt:
+		tc.CheckTupleDeclStmt(s)
 	case ast.StructDeclStmt:
 		tc.CheckStructDeclStmt(s)
+	case ast.TypeDeclStmt:
+		tc.CheckTypeDeclStmt(s)
+	case ast.InterfaceDeclStmt:
+		tc.CheckInterfaceDeclStmt(s)
 	case ast.FuncDeclStmt:
 		tc.CheckFuncDeclStmt(s)
 	case ast.IfStmt:
 		tc.CheckIfStmt(s)
 	case ast.ForStmt:
 		tc.CheckForStmt(s)
+	case ast.ForEachStmt:
+		tc.CheckForEachStmt(s)
 	case ast.ReturnStmt:
 		tc.CheckReturnStmt(s)
+	case ast.DeferStmt:
+		tc.CheckDeferStmt(s)
+	case ast.StaticAssertStmt:
+		tc.CheckStaticAssertStmt(s)
+	case ast.AssertStmt:
+		tc.CheckAssertStmt(s)
 	case ast.ExpressionStmt:
 		tc.InferType(s.Expr)
 	default:
@@ -290,6 +729,10 @@ func (tc *TypeChecker) CheckStmt(stmt ast.Stmt) {
 }
 
 func (tc *TypeChecker) CheckVarDeclStmt(stmt ast.VarDeclStmt) {
+	if stmt.Var.Type == nil {
+		tc.CheckInferredVarDeclStmt(stmt)
+		return
+	}
 	declaredType := tc.ResolveType(stmt.Var.Type)
 	if declaredType == nil {
 		return
@@ -299,87 +742,603 @@ func (tc *TypeChecker) CheckVarDeclStmt(stmt ast.VarDeclStmt) {
 		if initType == nil {
 			return
 		}
-		if !declaredType.Equals(initType) {
-			tc.Err(fmt.Sprintf("type mismatch: variable %s declared as %s but initialized with %s", stmt.Var.Name, declaredType, initType))
+		if !Assignable(declaredType, initType) {
+			tc.Err(fmt.Sprintf("type mismatch: variable %s declared as %s but initialized with %s%s", stmt.Var.Name, declaredType, initType, ConformanceDetail(declaredType, initType)))
 		}
 	}
 	tc.env.DefineVar(stmt.Var.Name, declaredType)
 }
 
+func (tc *TypeChecker) CheckInferredVarDeclStmt(stmt ast.VarDeclStmt) {
+	if stmt.InitVal == nil {
+		tc.Err(fmt.Sprintf("cannot infer type of variable %s without an initializer", stmt.Var.Name))
+		return
+	}
+	initType := tc.InferType(stmt.InitVal)
+	if initType == nil {
+		return
+	}
+	switch {
+	case IsPrimitive(initType, "void"):
+		tc.Err(fmt.Sprintf("cannot infer type of variable %s from an expression of type void", stmt.Var.Name))
+		return
+	case IsIncomplete(initType):
+		tc.Err(fmt.Sprintf("cannot infer type of variable %s from %s", stmt.Var.Name, initType))
+		return
+	case tc.IsTypeName(stmt.InitVal):
+		tc.Err(fmt.Sprintf("cannot infer type of variable %s from type name %s", stmt.Var.Name, initType))
+		return
+	}
+	tc.env.DefineVar(stmt.Var.Name, initType)
+}
+
+func (tc *TypeChecker) IsTypeName(expr ast.Expr) bool {
+	ident, ok := expr.(ast.IdentExpr)
+	if !ok {
+		return false
+	}
+	_, ok = tc.LookupTypeName(ident.Value)
+	return ok
+}
+
+func (tc *TypeChecker) LookupTypeName(name string) (Type, bool) {
+	if _, ok := tc.env.LookupVarType(name); ok {
+		return nil, false
+	}
+	if prim, ok := tc.primitives[name]; ok {
+		return prim, true
+	}
+	if structType, ok := tc.env.LookupStructType(name); ok {
+		return structType, true
+	}
+	return tc.env.LookupType(name)
+}
+
+func (tc *TypeChecker) CheckTupleDeclStmt(stmt ast.TupleDeclStmt) {
+	initType := tc.InferType(stmt.InitVal)
+	if initType == nil {
+		return
+	}
+	tupleType, ok := initType.(TupleType)
+	if !ok {
+		tc.Err(fmt.Sprintf("cannot destructure non-tuple type %s", initType))
+		return
+	}
+	if len(stmt.Vars) != len(tupleType.ElemTypes) {
+		tc.Err(fmt.Sprintf("wrong number of variables in destructuring, expected %d, found %d", len(tupleType.ElemTypes), len(stmt.Vars)))
+		return
+	}
+	for i, v := range stmt.Vars {
+		if slices.ContainsFunc(stmt.Vars[:i], func(prev ast.TypedIdent) bool { return prev.Name == v.Name }) {
+			tc.Err(fmt.Sprintf("duplicate variable %s in destructuring", v.Name))
+			return
+		}
+	}
+	for i, v := range stmt.Vars {
+		elemType := tupleType.ElemTypes[i]
+		if v.Type != nil {
+			declaredType := tc.ResolveType(v.Type)
+			if declaredType == nil {
+				continue
+			}
+			if !Assignable(declaredType, elemType) {
+				tc.Err(fmt.Sprintf("type mismatch: variable %s declared as %s but destructured from %s", v.Name, declaredType, elemType))
+				continue
+			}
+			elemType = declaredType
+		}
+		tc.env.DefineVar(v.Name, elemType)
+	}
+}
+
 func (tc *TypeChecker) CheckStructDeclStmt(stmt ast.StructDeclStmt) {
-	if _, ok := tc.env.LookupStructType(stmt.Name); ok {
-		tc.Err(fmt.Sprintf("redeclared struct %s in the same scope", stmt.Name))
+	tc.DeclareStructType(stmt, stmt.Name)
+}
+
+func (tc *TypeChecker) DeclareStructType(stmt ast.StructDeclStmt, qualifiedName string) {
+	if _, ok := tc.env.structTypes[stmt.Name]; ok {
+		tc.Err(fmt.Sprintf("redeclared struct %s in the same scope", qualifiedName))
 		return
 	}
+	tc.CheckAttributes(stmt.Attributes, StructTarget)
+	oldEnv := tc.env
+	tc.env = NewTypeEnv(oldEnv)
+	for _, nestedStruct := range stmt.NestedStructs {
+		tc.DeclareStructType(nestedStruct, qualifiedName+"."+nestedStruct.Name)
+	}
+	nestedTypes := tc.env.structTypes
 	members := make(map[string]Type)
+	embedded := []StructType{}
+	defaultedMembers := make(map[string]bool)
+	deprecatedMembers := make(map[string]string)
 	for _, member := range stmt.Members {
 		if _, ok := members[member.Name]; ok {
-			tc.Err(fmt.Sprintf("duplicate member %s in struct %s", member.Name, stmt.Name))
+			tc.Err(fmt.Sprintf("duplicate member %s in struct %s", member.Name, qualifiedName))
 			continue
 		}
+		tc.CheckAttributes(member.Attributes, StructMemberTarget)
+		if msg, ok := DeprecationMessage(member.Attributes); ok {
+			deprecatedMembers[member.Name] = msg
+		}
 		members[member.Name] = tc.ResolveType(member.Type)
+		if member.Embedded && members[member.Name] != nil {
+			embeddedType, ok := members[member.Name].(StructType)
+			if !ok {
+				tc.Err(fmt.Sprintf("cannot embed non-struct type %s in struct %s", members[member.Name], qualifiedName))
+				continue
+			}
+			embedded = append(embedded, embeddedType)
+		}
+		if member.Default != nil {
+			tc.CheckMemberDefault(qualifiedName, member.Name, members[member.Name], member.Default)
+			defaultedMembers[member.Name] = true
+		}
+	}
+	methods := make(map[string]FuncType)
+	methodDecls := []ast.FuncDeclStmt{}
+	operatorDecls := []ast.FuncDeclStmt{}
+	for _, method := range stmt.Methods {
+		if IsOperatorFuncName(method.Name) {
+			operatorDecls = append(operatorDecls, method)
+			continue
+		}
+		if _, ok := members[method.Name]; ok {
+			tc.Err(fmt.Sprintf("struct %s has both a member and a method named %s", qualifiedName, method.Name))
+			continue
+		}
+		if _, ok := methods[method.Name]; ok {
+			tc.Err(fmt.Sprintf("duplicate method %s in struct %s", method.Name, qualifiedName))
+			continue
+		}
+		if method.Extern {
+			tc.Err(fmt.Sprintf("method %s of struct %s cannot be extern", method.Name, qualifiedName))
+			continue
+		}
+		tc.CheckAttributes(method.Attributes, FuncTarget)
+		tc.CheckExternAttribute(method)
+		if msg, ok := DeprecationMessage(method.Attributes); ok {
+			deprecatedMembers[method.Name] = msg
+		}
+		methodType, ok := tc.ResolveFuncSignature(method)
+		if !ok {
+			continue
+		}
+		methods[method.Name] = methodType
+		methodDecls = append(methodDecls, method)
+	}
+	structType := StructType{
+		Name:              qualifiedName,
+		Members:           members,
+		Methods:           methods,
+		Embedded:          embedded,
+		NestedTypes:       nestedTypes,
+		DefaultedMembers:  defaultedMembers,
+		DeprecatedMembers: deprecatedMembers,
+	}
+	structType.DeprecatedMsg, structType.Deprecated = DeprecationMessage(stmt.Attributes)
+	tc.CheckPromotedMembers(structType)
+	oldEnv.DefineStructType(stmt.Name, structType)
+	for _, method := range methodDecls {
+		tc.CheckFuncBody(method, methods[method.Name], structType)
+	}
+	tc.env = oldEnv
+	for _, operator := range operatorDecls {
+		tc.CheckFuncDeclStmt(operator)
+	}
+}
+
+func (tc *TypeChecker) CheckPromotedMembers(structType StructType) {
+	reported := make(map[string]bool)
+	names := structType.MemberNames()
+	slices.Sort(names)
+	for _, name := range names {
+		if reported[name] {
+			continue
+		}
+		if _, owners := structType.LookupMember(name); len(owners) > 1 {
+			tc.Err(fmt.Sprintf("ambiguous member %s in struct %s, promoted from %s", name, structType.Name, strings.Join(owners, " and ")))
+			reported[name] = true
+		}
+	}
+}
+
+func (tc *TypeChecker) LookupNestedStructType(qualifiedName string) (StructType, bool) {
+	names := strings.Split(qualifiedName, ".")
+	structType, ok := tc.env.LookupStructType(names[0])
+	for _, name := range names[1:] {
+		if !ok {
+			break
+		}
+		structType, ok = structType.NestedTypes[name]
+	}
+	return structType, ok
+}
+
+func (tc *TypeChecker) LookupStructMember(structType StructType, name string) Type {
+	memberType, owners := structType.LookupMember(name)
+	if len(owners) == 0 {
+		if method, ok := structType.MethodSet()[name]; ok {
+			tc.WarnDeprecatedMember(structType, name)
+			return method
+		}
+	}
+	switch {
+	case len(owners) == 0:
+		tc.Err(fmt.Sprintf("%s is not a member of struct %s", name, structType.Name))
+		return nil
+	case len(owners) > 1:
+		tc.Err(fmt.Sprintf("ambiguous member %s of struct %s, promoted from %s", name, structType.Name, strings.Join(owners, " and ")))
+		return nil
+	}
+	tc.WarnDeprecatedMember(structType, name)
+	return memberType
+}
+
+func (tc *TypeChecker) CheckMemberDefault(structName string, memberName string, memberType Type, defaultVal ast.Expr) {
+	defaultType := tc.InferType(defaultVal)
+	if memberType == nil || defaultType == nil {
+		return
+	}
+	if !Assignable(memberType, defaultType) {
+		tc.Err(fmt.Sprintf("cannot use %s as default value of %s for struct member %s.%s", defaultType, memberType, structName, memberName))
+		return
+	}
+	if _, ok := defaultVal.(ast.NoneLiteralExpr); ok {
+		return
+	}
+	if _, err := EvalConstExpr(defaultVal); err != nil {
+		tc.Err(fmt.Sprintf("default value of struct member %s.%s must be a constant expression: %s", structName, memberName, err))
+	}
+}
+
+func (tc *TypeChecker) CheckTypeDeclStmt(stmt ast.TypeDeclStmt) {
+	if _, ok := tc.LookupTypeName(stmt.Name); ok {
+		tc.Err(fmt.Sprintf("redeclared type %s in the same scope", stmt.Name))
+		return
+	}
+	declaredType := tc.ResolveType(stmt.Type)
+	if declaredType == nil {
+		return
+	}
+	if stmt.Distinct {
+		declaredType = DistinctType{
+			Name:           stmt.Name,
+			UnderlyingType: declaredType,
+		}
+	}
+	tc.env.DefineType(stmt.Name, declaredType)
+}
+
+func (tc *TypeChecker) CheckFuncDeclStmt(stmt ast.FuncDeclStmt) {
+	if _, ok := tc.env.LookupFuncType(stmt.Name); ok {
+		tc.Err(fmt.Sprintf("redeclared function %s in the same scope", stmt.Name))
+		return
+	}
+	tc.CheckAttributes(stmt.Attributes, FuncTarget)
+	tc.CheckExternAttribute(stmt)
+	funcType, ok := tc.ResolveFuncSignature(stmt)
+	if !ok {
+		return
+	}
+	funcType.DeprecatedMsg, funcType.Deprecated = DeprecationMessage(stmt.Attributes)
+	if IsOperatorFuncName(stmt.Name) {
+		if tc.CheckOperatorFuncDecl(stmt, funcType) {
+			tc.CheckFuncBody(stmt, funcType, nil)
+		}
+		return
+	}
+	// The parameter names and defaults of a declared function are not part of
+	// its type string, so the name keeps functions of the same type apart.
+	funcTypeName := fmt.Sprintf("%s %s", stmt.Name, funcType)
+	tc.env.DefineFunc(stmt.Name, funcTypeName)
+	tc.env.DefineFuncType(funcTypeName, funcType)
+	if stmt.Extern {
+		tc.CheckExternFuncDecl(stmt, funcType)
+		return
+	}
+	tc.CheckFuncBody(stmt, funcType, nil)
+}
+
+func (tc *TypeChecker) ResolveFuncSignature(stmt ast.FuncDeclStmt) (FuncType, bool) {
+	returnType := tc.primitives["void"]
+	if stmt.ReturnType != nil {
+		returnType = tc.ResolveType(stmt.ReturnType)
+		if returnType == nil {
+			return FuncType{}, false
+		}
+	}
+	paramTypes := make([]Type, 0, len(stmt.Parameters))
+	paramNames := make([]string, 0, len(stmt.Parameters))
+	defaultParams := 0
+	for _, param := range stmt.Parameters {
+		paramType := tc.ResolveType(param.Type)
+		if paramType == nil {
+			return FuncType{}, false
+		}
+		if slices.Contains(paramNames, param.Name) {
+			tc.Err(fmt.Sprintf("duplicate parameter %s in function %s", param.Name, stmt.Name))
+			return FuncType{}, false
+		}
+		if param.Variadic {
+			if param.Default != nil {
+				tc.Err(fmt.Sprintf("variadic parameter %s of function %s cannot have a default value", param.Name, stmt.Name))
+				return FuncType{}, false
+			}
+			paramType = ArrayType{ElemType: paramType}
+		} else if param.Default != nil {
+			tc.CheckParamDefault(param, paramType)
+			defaultParams++
+		} else if defaultParams > 0 {
+			tc.Err(fmt.Sprintf("parameter %s of function %s must have a default value, since it follows a parameter with one", param.Name, stmt.Name))
+			return FuncType{}, false
+		}
+		paramTypes = append(paramTypes, paramType)
+		paramNames = append(paramNames, param.Name)
+	}
+	return FuncType{
+		ReturnType:    returnType,
+		ParamTypes:    paramTypes,
+		Variadic:      len(stmt.Parameters) > 0 && stmt.Parameters[len(stmt.Parameters)-1].Variadic,
+		ParamNames:    paramNames,
+		DefaultParams: defaultParams,
+	}, true
+}
+
+// Default values must be constant expressions, or none for an optional
+// parameter, so that a call can use them without evaluating anything in the
+// scope of the declaration.
+func (tc *TypeChecker) CheckParamDefault(param ast.Parameter, paramType Type) {
+	defaultType := tc.InferType(param.Default)
+	if defaultType == nil {
+		return
+	}
+	if !Assignable(paramType, defaultType) {
+		tc.Err(fmt.Sprintf("default value of parameter %s type mismatch: expected %s, found %s%s", param.Name, paramType, defaultType, ConformanceDetail(paramType, defaultType)))
+		return
+	}
+	if _, ok := param.Default.(ast.NoneLiteralExpr); ok {
+		return
+	}
+	if _, err := EvalConstExpr(param.Default); err != nil {
+		tc.Err(fmt.Sprintf("default value of parameter %s must be a constant expression: %s", param.Name, err))
+	}
+}
+
+func (tc *TypeChecker) CheckFuncBody(stmt ast.FuncDeclStmt, funcType FuncType, receiver Type) {
+	funcBodyEnv := NewTypeEnv(tc.env)
+	funcBodyEnv.currentFuncReturnType = funcType.ReturnType
+	if receiver != nil {
+		funcBodyEnv.DefineVar("self", receiver)
+	}
+	for i, param := range stmt.Parameters {
+		funcBodyEnv.DefineVar(param.Name, funcType.ParamTypes[i])
+	}
+	oldEnv := tc.env
+	tc.env = funcBodyEnv
+	tc.CheckBlockStmt(stmt.Body)
+	if !IsPrimitive(funcType.ReturnType, "void") {
+		if !tc.BlockReturns(stmt.Body) {
+			tc.Err(fmt.Sprintf("function '%s' with return type %s does not return a value in all code paths", stmt.Name, funcType.ReturnType))
+		}
+	}
+	tc.CheckUnreachableCode(stmt.Body)
+	tc.env = oldEnv
+}
+
+func (tc *TypeChecker) CheckIfStmt(stmt ast.IfStmt) {
+	condType := tc.InferType(stmt.Cond)
+	if !IsPrimitive(condType, "bool") {
+		tc.Err("if- statement condition does not evaluate to a boolean type")
+	}
+	someWhenTrue := NoneCheckedVars(stmt.Cond, lexer.NOT_EQUALS)
+	someWhenFalse := NoneCheckedVars(stmt.Cond, lexer.EQUALS)
+	tc.CheckNarrowedStmt(stmt.Then, someWhenTrue)
+	if stmt.Else != nil {
+		tc.CheckNarrowedStmt(stmt.Else, someWhenFalse)
+	}
+	thenReturns := tc.StmtReturns(stmt.Then)
+	elseReturns := stmt.Else != nil && tc.StmtReturns(stmt.Else)
+	switch {
+	case thenReturns && !elseReturns:
+		tc.NarrowVars(someWhenFalse)
+	case elseReturns && !thenReturns:
+		tc.NarrowVars(someWhenTrue)
+	}
+}
+
+func NoneCheckedVars(cond ast.Expr, operator lexer.TokenType) []string {
+	switch e := cond.(type) {
+	case ast.GroupExpr:
+		return NoneCheckedVars(e.Expr, operator)
+	case ast.BinaryExpr:
+		if (operator == lexer.NOT_EQUALS && e.Operator.Type == lexer.AND) || (operator == lexer.EQUALS && e.Operator.Type == lexer.OR) {
+			return append(NoneCheckedVars(e.Lhs, operator), NoneCheckedVars(e.Rhs, operator)...)
+		}
+		if e.Operator.Type != operator {
+			return nil
+		}
+		lhs, lhsIsIdent := e.Lhs.(ast.IdentExpr)
+		rhs, rhsIsIdent := e.Rhs.(ast.IdentExpr)
+		_, lhsIsNone := e.Lhs.(ast.NoneLiteralExpr)
+		_, rhsIsNone := e.Rhs.(ast.NoneLiteralExpr)
+		switch {
+		case lhsIsIdent && rhsIsNone:
+			return []string{lhs.Value}
+		case rhsIsIdent && lhsIsNone:
+			return []string{rhs.Value}
+		}
+	}
+	return nil
+}
+
+func (tc *TypeChecker) NarrowVars(names []string) {
+	for _, name := range names {
+		if varType, ok := tc.env.LookupVarType(name); ok {
+			if optionalType, ok := varType.(OptionalType); ok {
+				tc.env.NarrowVar(name, optionalType.ElemType)
+			}
+		}
+	}
+}
+
+// Widens the variables that a loop assigns, since a later iteration can
+// observe the assignment before a check that narrowed them is repeated.
+func (tc *TypeChecker) WidenAssignedVars(stmts ...ast.Stmt) {
+	for _, stmt := range stmts {
+		for _, name := range AssignedVars(stmt) {
+			tc.env.WidenVar(name)
+		}
+	}
+}
+
+// Returns the names of the variables that stmt assigns to, outside of the
+// functions it declares.
+func AssignedVars(stmt ast.Stmt) []string {
+	switch s := stmt.(type) {
+	case ast.BlockStmt:
+		names := []string{}
+		for _, inner := range s.Body {
+			names = append(names, AssignedVars(inner)...)
+		}
+		return names
+	case ast.ExpressionStmt:
+		return AssignedVarsInExpr(s.Expr)
+	case ast.VarDeclStmt:
+		return AssignedVarsInExpr(s.InitVal)
+	case ast.TupleDeclStmt:
+		return AssignedVarsInExpr(s.InitVal)
+	case ast.IfStmt:
+		names := append(AssignedVarsInExpr(s.Cond), AssignedVars(s.Then)...)
+		return append(names, AssignedVars(s.Else)...)
+	case ast.ForStmt:
+		names := append(AssignedVars(s.Init), AssignedVarsInExpr(s.Cond)...)
+		names = append(names, AssignedVars(s.Iter)...)
+		return append(names, AssignedVars(s.Body)...)
+	case ast.ForEachStmt:
+		return append(AssignedVarsInExpr(s.Iterable), AssignedVars(s.Body)...)
+	case ast.ReturnStmt:
+		return AssignedVarsInExpr(s.Expr)
+	case ast.DeferStmt:
+		return AssignedVarsInExpr(s.Expr)
+	case ast.AssertStmt:
+		return AssignedVarsInExpr(s.Cond)
+	}
+	return nil
+}
+
+func AssignedVarsInExpr(expr ast.Expr) []string {
+	switch e := expr.(type) {
+	case ast.AssignExpr:
+		names := AssignedVarsInExpr(e.AssignedValue)
+		if ident, ok := e.Assigne.(ast.IdentExpr); ok {
+			return append(names, ident.Value)
+		}
+		return append(names, AssignedVarsInExpr(e.Assigne)...)
+	case ast.UnaryExpr:
+		return AssignedVarsInExpr(e.Rhs)
+	case ast.BinaryExpr:
+		return append(AssignedVarsInExpr(e.Lhs), AssignedVarsInExpr(e.Rhs)...)
+	case ast.GroupExpr:
+		return AssignedVarsInExpr(e.Expr)
+	case ast.TupleExpr:
+		return assignedVarsInExprs(e.Elems)
+	case ast.InterpolatedStringExpr:
+		return assignedVarsInExprs(e.Exprs)
+	case ast.FuncCallExpr:
+		return append(AssignedVarsInExpr(e.Func), assignedVarsInExprs(e.Args)...)
+	case ast.SpreadExpr:
+		return AssignedVarsInExpr(e.Expr)
+	case ast.NamedArgExpr:
+		return AssignedVarsInExpr(e.Value)
+	case ast.MapLiteralExpr:
+		names := []string{}
+		for _, entry := range e.Entries {
+			names = append(names, AssignedVarsInExpr(entry.Key)...)
+			names = append(names, AssignedVarsInExpr(entry.Value)...)
+		}
+		return names
+	case ast.StructLiteralExpr:
+		names := AssignedVarsInExpr(e.Base)
+		for _, member := range e.Members {
+			names = append(names, AssignedVarsInExpr(member.Value)...)
+		}
+		return names
+	case ast.StructMemberExpr:
+		return AssignedVarsInExpr(e.Struct)
+	case ast.OptionalMemberExpr:
+		return AssignedVarsInExpr(e.Struct)
+	case ast.TryExpr:
+		return AssignedVarsInExpr(e.Expr)
+	case ast.ArrayIndexExpr:
+		return append(AssignedVarsInExpr(e.Array), AssignedVarsInExpr(e.Index)...)
+	case ast.SliceExpr:
+		names := append(AssignedVarsInExpr(e.Array), AssignedVarsInExpr(e.Start)...)
+		return append(names, AssignedVarsInExpr(e.End)...)
+	case ast.IfExpr:
+		names := append(AssignedVarsInExpr(e.Cond), AssignedVarsInExpr(e.Then)...)
+		return append(names, AssignedVarsInExpr(e.Else)...)
+	case ast.RangeExpr:
+		return append(AssignedVarsInExpr(e.Start), AssignedVarsInExpr(e.End)...)
 	}
-	tc.env.DefineStructType(stmt.Name, StructType{
-		Name:    stmt.Name,
-		Members: members,
-	})
+	return nil
 }
 
-func (tc *TypeChecker) CheckFuncDeclStmt(stmt ast.FuncDeclStmt) {
-	if _, ok := tc.env.LookupFuncType(stmt.Name); ok {
-		tc.Err(fmt.Sprintf("redeclared function %s in the same scope", stmt.Name))
-		return
+func assignedVarsInExprs(exprs []ast.Expr) []string {
+	names := []string{}
+	for _, expr := range exprs {
+		names = append(names, AssignedVarsInExpr(expr)...)
 	}
-	returnType := tc.primitives["void"]
-	if stmt.ReturnType != nil {
-		returnType = tc.ResolveType(stmt.ReturnType)
-		if returnType == nil {
-			return
-		}
+	return names
+}
+
+func (tc *TypeChecker) CheckIfExpr(expr ast.IfExpr) Type {
+	condType := tc.InferType(expr.Cond)
+	if !IsPrimitive(condType, "bool") {
+		tc.Err("if- expression condition does not evaluate to a boolean type")
 	}
-	paramTypes := make([]Type, 0, len(stmt.Parameters))
-	funcBodyEnv := NewTypeEnv(tc.env)
-	funcBodyEnv.currentFuncReturnType = returnType
-	for _, param := range stmt.Parameters {
-		paramType := tc.ResolveType(param.Type)
-		if paramType == nil {
-			return
-		}
-		paramTypes = append(paramTypes, paramType)
-		funcBodyEnv.DefineVar(param.Name, paramType)
+	thenType := tc.InferNarrowedType(expr.Then, NoneCheckedVars(expr.Cond, lexer.NOT_EQUALS))
+	if expr.Else == nil {
+		tc.Err("if- expression requires an else branch")
+		return nil
 	}
-	funcType := FuncType{
-		ReturnType: returnType,
-		ParamTypes: paramTypes,
+	elseType := tc.InferNarrowedType(expr.Else, NoneCheckedVars(expr.Cond, lexer.EQUALS))
+	if thenType == nil || elseType == nil {
+		return nil
 	}
-	funcTypeName := fmt.Sprintf("%s", funcType)
-	tc.env.DefineFunc(stmt.Name, funcTypeName)
-	tc.env.DefineFuncType(funcTypeName, funcType)
-	oldEnv := tc.env
-	tc.env = funcBodyEnv
-	tc.CheckBlockStmt(stmt.Body)
-	if returnType != nil && !IsPrimitive(returnType, "void") {
-		if !tc.BlockReturns(stmt.Body) {
-			tc.Err(fmt.Sprintf("function '%s' with return type %s does not return a value in all code paths", stmt.Name, returnType))
-		}
+	switch {
+	case Assignable(thenType, elseType):
+		return thenType
+	case Assignable(elseType, thenType):
+		return elseType
+	case elseType.Equals(NoneType{}) && !IsIncomplete(thenType):
+		return OptionalType{ElemType: thenType}
+	case thenType.Equals(NoneType{}) && !IsIncomplete(elseType):
+		return OptionalType{ElemType: elseType}
 	}
-	tc.CheckUnreachableCode(stmt.Body)
+	tc.Err(fmt.Sprintf("if- expression branches have different types: %s and %s", thenType, elseType))
+	return nil
+}
+
+func (tc *TypeChecker) InferNarrowedType(expr ast.Expr, names []string) Type {
+	oldEnv := tc.env
+	tc.env = NewTypeEnv(oldEnv)
+	tc.NarrowVars(names)
+	exprType := tc.InferType(expr)
 	tc.env = oldEnv
+	return exprType
 }
 
-func (tc *TypeChecker) CheckIfStmt(stmt ast.IfStmt) {
-	condType := tc.InferType(stmt.Cond)
-	if !IsPrimitive(condType, "bool") {
-		tc.Err("if- statement condition does not evaluate to a boolean type")
-	}
-	tc.CheckStmt(stmt.Then)
-	if stmt.Else != nil {
-		tc.CheckStmt(stmt.Else)
-	}
+func (tc *TypeChecker) CheckNarrowedStmt(stmt ast.Stmt, names []string) {
+	oldEnv := tc.env
+	tc.env = NewTypeEnv(oldEnv)
+	tc.NarrowVars(names)
+	tc.CheckStmt(stmt)
+	tc.env = oldEnv
 }
 
 func (tc *TypeChecker) CheckForStmt(stmt ast.ForStmt) {
 	tc.CheckStmt(stmt.Init)
+	tc.WidenAssignedVars(ast.ExpressionStmt{Expr: stmt.Cond}, stmt.Iter, stmt.Body)
 	condType := tc.InferType(stmt.Cond)
 	if !IsPrimitive(condType, "bool") {
 		tc.Err("for- statement condition does not evaluate to a boolean type")
@@ -388,6 +1347,48 @@ func (tc *TypeChecker) CheckForStmt(stmt ast.ForStmt) {
 	tc.CheckBlockStmt(stmt.Body)
 }
 
+func (tc *TypeChecker) CheckForEachStmt(stmt ast.ForEachStmt) {
+	var indexType, elemType Type
+	switch iterable := stmt.Iterable.(type) {
+	case ast.RangeExpr:
+		startType := tc.InferType(iterable.Start)
+		endType := tc.InferType(iterable.End)
+		if startType == nil || endType == nil {
+			return
+		}
+		if !IsInteger(startType) || !startType.Equals(endType) {
+			tc.Err(fmt.Sprintf("invalid range bounds: %s and %s", startType, endType))
+			return
+		}
+		if stmt.Index != "" {
+			tc.Err("cannot use an index variable when iterating over a range")
+			return
+		}
+		elemType = startType
+	default:
+		iterableType := tc.InferType(stmt.Iterable)
+		if iterableType == nil {
+			return
+		}
+		arrayType, ok := iterableType.(ArrayType)
+		if !ok {
+			tc.Err(fmt.Sprintf("cannot iterate over non-array type %s", iterableType))
+			return
+		}
+		indexType = tc.primitives["i32"]
+		elemType = arrayType.ElemType
+	}
+	oldEnv := tc.env
+	tc.env = NewTypeEnv(oldEnv)
+	if stmt.Index != "" {
+		tc.env.DefineVar(stmt.Index, indexType)
+	}
+	tc.env.DefineVar(stmt.Elem, elemType)
+	tc.WidenAssignedVars(stmt.Body)
+	tc.CheckBlockStmt(stmt.Body)
+	tc.env = oldEnv
+}
+
 func (tc *TypeChecker) CheckReturnStmt(stmt ast.ReturnStmt) {
 	if tc.env.currentFuncReturnType == nil {
 		tc.Err("return statement outside of function")
@@ -406,28 +1407,92 @@ func (tc *TypeChecker) CheckReturnStmt(stmt ast.ReturnStmt) {
 		return
 	case isVoidReturn:
 		tc.Err("cannot return a value from a void function")
-	case !exprType.Equals(tc.env.currentFuncReturnType):
-		tc.Err(fmt.Sprintf("return type mismatch: expected %s, found %s", tc.env.currentFuncReturnType, exprType))
+	case !Assignable(tc.env.currentFuncReturnType, exprType):
+		tc.Err(fmt.Sprintf("return type mismatch: expected %s, found %s%s", tc.env.currentFuncReturnType, exprType, ConformanceDetail(tc.env.currentFuncReturnType, exprType)))
+	}
+}
+
+func (tc *TypeChecker) CheckDeferStmt(stmt ast.DeferStmt) {
+	if tc.env.currentFuncReturnType == nil {
+		tc.Err("defer statement outside of function")
+		return
+	}
+	call, ok := stmt.Expr.(ast.FuncCallExpr)
+	if !ok {
+		tc.Err(fmt.Sprintf("deferred expression must be a function call, found %s", describeExpr(stmt.Expr)))
+		return
+	}
+	tc.CheckFuncCallExpr(call)
+}
+
+func describeExpr(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case ast.NumberLiteralExpr, ast.StringLiteralExpr, ast.InterpolatedStringExpr, ast.BoolLiteralExpr, ast.NoneLiteralExpr:
+		return "literal"
+	case ast.IdentExpr:
+		return fmt.Sprintf("identifier %s", e.Value)
+	case ast.UnaryExpr, ast.BinaryExpr:
+		return "operator expression"
+	case ast.GroupExpr:
+		return describeExpr(e.Expr)
+	case ast.TupleExpr:
+		return "tuple"
+	case ast.MapLiteralExpr:
+		return "map literal"
+	case ast.StructLiteralExpr:
+		return "struct literal"
+	case ast.StructMemberExpr:
+		return fmt.Sprintf("member access .%s", e.Member.Value)
+	case ast.OptionalMemberExpr:
+		return fmt.Sprintf("member access ?.%s", e.Member.Value)
+	case ast.TryExpr:
+		return "? expression"
+	case ast.ArrayIndexExpr:
+		return "index expression"
+	case ast.SliceExpr:
+		return "slice expression"
+	case ast.IfExpr:
+		return "if expression"
+	case ast.RangeExpr:
+		return "range"
+	case ast.AssignExpr:
+		return "assignment"
+	default:
+		return "expression"
 	}
 }
 
 func (tc *TypeChecker) InferType(expr ast.Expr) Type {
 	switch e := expr.(type) {
 	case ast.NumberLiteralExpr:
+		if strings.Contains(e.Value, ".") {
+			return tc.primitives["f32"]
+		}
 		return tc.primitives["i32"] // todo; evaluate the number literal to determine exact type
 	case ast.StringLiteralExpr:
 		return tc.primitives["string"]
+	case ast.InterpolatedStringExpr:
+		return tc.CheckInterpolatedStringExpr(e)
 	case ast.BoolLiteralExpr:
 		return tc.primitives["bool"]
+	case ast.NoneLiteralExpr:
+		return NoneType{}
 	case ast.IdentExpr:
 		if varType, ok := tc.env.LookupVarType(e.Value); ok {
 			return varType
 		}
 		if structType, ok := tc.env.LookupStructType(e.Value); ok {
+			tc.WarnDeprecatedStruct(structType)
 			return structType
 		}
+		if declaredType, ok := tc.env.LookupType(e.Value); ok {
+			return declaredType
+		}
 		if funcTypeName, ok := tc.env.LookupFunc(e.Value); ok {
 			if funcType, ok := tc.env.LookupFuncType(funcTypeName); ok {
+				if funcType.Deprecated {
+					tc.WarnDeprecated("function", e.Value, funcType.DeprecatedMsg)
+				}
 				return funcType
 			}
 		}
@@ -439,14 +1504,32 @@ func (tc *TypeChecker) InferType(expr ast.Expr) Type {
 		return tc.CheckUnaryExpr(e)
 	case ast.GroupExpr:
 		return tc.InferType(e.Expr)
+	case ast.TupleExpr:
+		return tc.CheckTupleExpr(e)
 	case ast.FuncCallExpr:
 		return tc.CheckFuncCallExpr(e)
 	case ast.StructLiteralExpr:
 		return tc.CheckStructLiteralExpr(e)
 	case ast.StructMemberExpr:
 		return tc.CheckStructMemberExpr(e)
+	case ast.OptionalMemberExpr:
+		return tc.CheckOptionalMemberExpr(e)
+	case ast.TryExpr:
+		return tc.CheckTryExpr(e)
+	case ast.IfExpr:
+		return tc.CheckIfExpr(e)
 	case ast.ArrayIndexExpr:
 		return tc.CheckArrayIndexExpr(e)
+	case ast.SliceExpr:
+		return tc.CheckSliceExpr(e)
+	case ast.NamedArgExpr:
+		tc.Err(fmt.Sprintf("named argument %s is only allowed in a function call", e.Name))
+		return nil
+	case ast.SpreadExpr:
+		tc.Err("spread argument is only allowed for the variadic parameter of a function call")
+		return nil
+	case ast.MapLiteralExpr:
+		return tc.CheckMapLiteralExpr(e)
 	case ast.AssignExpr:
 		return tc.CheckAssignExpr(e)
 	default:
@@ -463,24 +1546,36 @@ func (tc *TypeChecker) CheckBinaryExpr(expr ast.BinaryExpr) Type {
 	}
 	switch expr.Operator.Type {
 	case lexer.PLUS, lexer.DASH, lexer.STAR, lexer.SLASH, lexer.PERCENT:
+		if IsDistinctNumeric(leftType) && leftType.Equals(rightType) {
+			return leftType
+		}
 		if IsNumeric(leftType) && IsNumeric(rightType) {
 			return leftType // no specific reason, just pick one arbitrarily until we have e.g. type promotion (i32 -> f32 etc.)
 		}
 		if expr.Operator.Type == lexer.PLUS && IsPrimitive(leftType, "string") && IsPrimitive(rightType, "string") {
 			return tc.primitives["string"]
 		}
+		if hasStructOperand([]Type{leftType, rightType}) {
+			return tc.CheckOperatorOverload(expr.Operator, BinaryOperatorFuncs[expr.Operator.Type], leftType, rightType)
+		}
 		tc.Err(fmt.Sprintf("invalid operands for %s: %s and %s", expr.Operator.Value, leftType, rightType))
 		return nil
 	case lexer.EQUALS, lexer.NOT_EQUALS:
-		if !leftType.Equals(rightType) {
+		if hasStructOperand([]Type{leftType, rightType}) && len(tc.MatchOperatorOverloads("op_eq", []Type{leftType, rightType})) > 0 {
+			return tc.CheckOperatorOverload(expr.Operator, "op_eq", leftType, rightType)
+		}
+		if !Assignable(leftType, rightType) && !Assignable(rightType, leftType) {
 			tc.Err(fmt.Sprintf("cannot compare %s and %s", leftType, rightType))
 			return nil
 		}
 		return tc.primitives["bool"]
 	case lexer.LESS, lexer.LESS_EQUALS, lexer.GREATER, lexer.GREATER_EQUALS:
-		if IsNumeric(leftType) && IsNumeric(rightType) {
+		if IsNumeric(leftType) && IsNumeric(rightType) || IsDistinctNumeric(leftType) && leftType.Equals(rightType) {
 			return tc.primitives["bool"]
 		}
+		if hasStructOperand([]Type{leftType, rightType}) {
+			return tc.CheckOperatorOverload(expr.Operator, BinaryOperatorFuncs[expr.Operator.Type], leftType, rightType)
+		}
 		tc.Err(fmt.Sprintf("invalid operands for %s: %s and %s", expr.Operator.Value, leftType, rightType))
 		return nil
 	case lexer.OR, lexer.AND:
@@ -489,6 +1584,20 @@ func (tc *TypeChecker) CheckBinaryExpr(expr ast.BinaryExpr) Type {
 		}
 		tc.Err(fmt.Sprintf("invalid operands for %s: %s and %s", expr.Operator.Value, leftType, rightType))
 		return nil
+	case lexer.QUESTION_QUESTION:
+		optionalType, ok := leftType.(OptionalType)
+		if !ok {
+			tc.Err(fmt.Sprintf("left operand of %s must be optional, found %s", expr.Operator.Value, leftType))
+			return nil
+		}
+		if Assignable(optionalType.ElemType, rightType) {
+			return optionalType.ElemType
+		}
+		if Assignable(optionalType, rightType) {
+			return optionalType
+		}
+		tc.Err(fmt.Sprintf("invalid operands for %s: %s and %s", expr.Operator.Value, leftType, rightType))
+		return nil
 	default:
 		tc.Err(fmt.Sprintf("unsupported binary operator: %s", expr.Operator.Value))
 		return nil
@@ -502,15 +1611,21 @@ func (tc *TypeChecker) CheckUnaryExpr(expr ast.UnaryExpr) Type {
 	}
 	switch expr.Operator.Type {
 	case lexer.PLUS, lexer.DASH:
-		if IsNumeric(operandType) {
+		if IsNumeric(operandType) || IsDistinctNumeric(operandType) {
 			return operandType
 		}
+		if name, ok := UnaryOperatorFuncs[expr.Operator.Type]; ok && hasStructOperand([]Type{operandType}) {
+			return tc.CheckOperatorOverload(expr.Operator, name, operandType)
+		}
 		tc.Err(fmt.Sprintf("invalid operand for %s: %s", expr.Operator.Value, operandType))
 		return nil
 	case lexer.NOT:
 		if IsPrimitive(operandType, "bool") {
 			return tc.primitives["bool"]
 		}
+		if hasStructOperand([]Type{operandType}) {
+			return tc.CheckOperatorOverload(expr.Operator, UnaryOperatorFuncs[expr.Operator.Type], operandType)
+		}
 		tc.Err(fmt.Sprintf("invalid operand for %s: %s", expr.Operator.Value, operandType))
 		return nil
 	default:
@@ -519,8 +1634,59 @@ func (tc *TypeChecker) CheckUnaryExpr(expr ast.UnaryExpr) Type {
 	}
 }
 
+func (tc *TypeChecker) CheckInterpolatedStringExpr(expr ast.InterpolatedStringExpr) Type {
+	for _, embeddedExpr := range expr.Exprs {
+		exprType := tc.InferType(embeddedExpr)
+		if exprType == nil {
+			continue
+		}
+		if !IsPrintable(exprType) {
+			tc.Err(fmt.Sprintf("cannot interpolate value of non-printable type %s into a string", exprType))
+		}
+	}
+	return tc.primitives["string"]
+}
+
+func (tc *TypeChecker) CheckTupleExpr(expr ast.TupleExpr) Type {
+	elemTypes := make([]Type, 0, len(expr.Elems))
+	for _, elem := range expr.Elems {
+		elemType := tc.InferType(elem)
+		if elemType == nil {
+			return nil
+		}
+		if IsPrimitive(elemType, "void") {
+			tc.Err("tuple element cannot be of type void")
+			return nil
+		}
+		elemTypes = append(elemTypes, elemType)
+	}
+	return TupleType{ElemTypes: elemTypes}
+}
+
 func (tc *TypeChecker) CheckFuncCallExpr(expr ast.FuncCallExpr) Type {
-	funcType := tc.InferType(expr.Func)
+	if ident, ok := expr.Func.(ast.IdentExpr); ok {
+		if targetType, ok := tc.LookupTypeName(ident.Value); ok {
+			if _, isStruct := targetType.(StructType); !isStruct {
+				return tc.CheckConversionExpr(targetType, expr.Args)
+			}
+		}
+		if ident.Value == "ok" || ident.Value == "err" {
+			if _, isVar := tc.env.LookupVarType(ident.Value); !isVar {
+				if _, isFunc := tc.env.LookupFunc(ident.Value); !isFunc {
+					return tc.CheckResultConstructorExpr(ident.Value, expr.Args)
+				}
+			}
+		}
+	}
+	// A call through ?. skips the call when the receiver is none, so its result
+	// is optional as well.
+	var funcType Type
+	member, optionalCall := expr.Func.(ast.OptionalMemberExpr)
+	if optionalCall {
+		funcType, optionalCall = tc.LookupOptionalMember(member)
+	} else {
+		funcType = tc.InferType(expr.Func)
+	}
 	if funcType == nil {
 		return nil
 	}
@@ -529,23 +1695,213 @@ func (tc *TypeChecker) CheckFuncCallExpr(expr ast.FuncCallExpr) Type {
 		tc.Err(fmt.Sprintf("cannot call non-function value of type %s", funcType))
 		return nil
 	}
-	if len(expr.Args) != len(ft.ParamTypes) {
-		tc.Err(fmt.Sprintf("wrong number of arguments, expected %d, found %d", len(ft.ParamTypes), len(expr.Args)))
+	args, variadicArgs, ok := tc.BindArgs(ft, expr.Args)
+	if !ok {
+		return nil
+	}
+	if ft.Variadic && !tc.CheckVariadicArgs(ft.ParamTypes[len(ft.ParamTypes)-1].(ArrayType), variadicArgs, len(args)) {
 		return nil
 	}
-	for i, arg := range expr.Args {
+	for i, arg := range args {
+		if arg == nil {
+			continue
+		}
 		argType := tc.InferType(arg)
 		if argType == nil {
 			return nil
 		}
-		if !ft.ParamTypes[i].Equals(argType) {
-			tc.Err(fmt.Sprintf("argument %d type mismatch: expected %s, found %s", i+1, ft.ParamTypes[i], argType))
+		if !Assignable(ft.ParamTypes[i], argType) {
+			tc.Err(fmt.Sprintf("argument %s type mismatch: expected %s, found %s%s", ArgName(ft, expr.Args, i), ft.ParamTypes[i], argType, ConformanceDetail(ft.ParamTypes[i], argType)))
 			return nil
 		}
 	}
+	if optionalCall {
+		if IsPrimitive(ft.ReturnType, "void") {
+			return ft.ReturnType
+		}
+		return MakeOptional(ft.ReturnType)
+	}
 	return ft.ReturnType
 }
 
+// BindArgs matches the arguments of a call to the parameters of ft, first by
+// position and then by name. The first result has one entry per parameter
+// other than the variadic one, nil for the parameters left to their default
+// values. The second holds the positional arguments past those parameters,
+// which go to the variadic parameter.
+func (tc *TypeChecker) BindArgs(ft FuncType, args []ast.Expr) ([]ast.Expr, []ast.Expr, bool) {
+	fixed := ft.FixedParams()
+	bound := make([]ast.Expr, fixed)
+	positional := 0
+	for positional < len(args) {
+		if _, ok := args[positional].(ast.NamedArgExpr); ok {
+			break
+		}
+		positional++
+	}
+	if positional > fixed && !ft.Variadic {
+		tc.Err(fmt.Sprintf("wrong number of arguments, expected %d, found %d", len(ft.ParamTypes), positional))
+		return nil, nil, false
+	}
+	var variadicArgs []ast.Expr
+	if positional > fixed {
+		variadicArgs = args[fixed:positional]
+		copy(bound, args[:fixed])
+	} else {
+		copy(bound, args[:positional])
+	}
+	ok := true
+	for _, arg := range args[positional:] {
+		namedArg, isNamed := arg.(ast.NamedArgExpr)
+		switch {
+		case !isNamed:
+			tc.Err("positional argument after named arguments")
+			ok = false
+			continue
+		case ft.ParamNames == nil:
+			tc.Err(fmt.Sprintf("named argument %s requires a call to a declared function", namedArg.Name))
+			return nil, nil, false
+		}
+		i := slices.Index(ft.ParamNames, namedArg.Name)
+		switch {
+		case i >= fixed:
+			tc.Err(fmt.Sprintf("variadic parameter %s cannot be passed by name", namedArg.Name))
+			ok = false
+		case i < 0:
+			tc.Err(fmt.Sprintf("unknown parameter %s in named argument", namedArg.Name))
+			ok = false
+		case bound[i] != nil:
+			tc.Err(fmt.Sprintf("duplicate argument for parameter %s", namedArg.Name))
+			ok = false
+		default:
+			bound[i] = namedArg.Value
+		}
+	}
+	if !ok {
+		return nil, nil, false
+	}
+	required := fixed - ft.DefaultParams
+	if ft.ParamNames == nil && positional < required {
+		tc.Err(fmt.Sprintf("wrong number of arguments, expected %d, found %d", len(ft.ParamTypes), positional))
+		return nil, nil, false
+	}
+	for i := range required {
+		if bound[i] == nil {
+			tc.Err(fmt.Sprintf("missing argument for parameter %s", ft.ParamNames[i]))
+			ok = false
+		}
+	}
+	return bound, variadicArgs, ok
+}
+
+// Checks the arguments passed to a variadic parameter: either any number of
+// elements, or a single array spread with "...". fixed is the number of
+// parameters before the variadic one, for numbering the arguments.
+func (tc *TypeChecker) CheckVariadicArgs(arrayType ArrayType, args []ast.Expr, fixed int) bool {
+	for i, arg := range args {
+		spread, isSpread := arg.(ast.SpreadExpr)
+		if isSpread && len(args) > 1 {
+			tc.Err("spread argument cannot be combined with other variadic arguments")
+			return false
+		}
+		expected := arrayType.ElemType
+		if isSpread {
+			arg = spread.Expr
+			expected = arrayType
+		}
+		argType := tc.InferType(arg)
+		if argType == nil {
+			return false
+		}
+		if !Assignable(expected, argType) {
+			tc.Err(fmt.Sprintf("argument %d type mismatch: expected %s, found %s%s", fixed+i+1, expected, argType, ConformanceDetail(expected, argType)))
+			return false
+		}
+	}
+	return true
+}
+
+// The number of a positional argument, or the name of a named one.
+func ArgName(ft FuncType, args []ast.Expr, i int) string {
+	if i < len(args) {
+		if _, ok := args[i].(ast.NamedArgExpr); !ok {
+			return fmt.Sprintf("%d", i+1)
+		}
+	}
+	return ft.ParamNames[i]
+}
+
+func (tc *TypeChecker) CheckConversionExpr(targetType Type, args []ast.Expr) Type {
+	if len(args) != 1 {
+		tc.Err(fmt.Sprintf("conversion to %s takes exactly one argument, found %d", targetType, len(args)))
+		return nil
+	}
+	argType := tc.InferType(args[0])
+	if argType == nil {
+		return nil
+	}
+	from, to := Underlying(argType), Underlying(targetType)
+	if !from.Equals(to) && !(IsNumeric(from) && IsNumeric(to)) {
+		tc.Err(fmt.Sprintf("cannot convert %s to %s", argType, targetType))
+		return nil
+	}
+	return targetType
+}
+
+func (tc *TypeChecker) CheckResultConstructorExpr(name string, args []ast.Expr) Type {
+	if name == "ok" && len(args) == 0 {
+		return ResultOkType{ValueType: tc.primitives["void"]}
+	}
+	if len(args) != 1 {
+		tc.Err(fmt.Sprintf("%s takes exactly one argument, found %d", name, len(args)))
+		return nil
+	}
+	argType := tc.InferType(args[0])
+	if argType == nil {
+		return nil
+	}
+	if IsPrimitive(argType, "void") {
+		tc.Err(fmt.Sprintf("cannot pass a void value to %s", name))
+		return nil
+	}
+	if name == "ok" {
+		return ResultOkType{ValueType: argType}
+	}
+	return ResultErrType{ErrType: argType}
+}
+
+func (tc *TypeChecker) CheckTryExpr(expr ast.TryExpr) Type {
+	operandType := tc.InferType(expr.Expr)
+	if operandType == nil {
+		return nil
+	}
+	resultType, ok := operandType.(ResultType)
+	if !ok {
+		tc.Err(fmt.Sprintf("? operator applied to non-result type %s", operandType))
+		return nil
+	}
+	return tc.CheckTry(resultType)
+}
+
+// Checks that the error of resultType can be propagated from the current
+// function, and returns the type of the value.
+func (tc *TypeChecker) CheckTry(resultType ResultType) Type {
+	if tc.env.currentFuncReturnType == nil {
+		tc.Err("? operator used outside of function")
+		return nil
+	}
+	funcResultType, ok := tc.env.currentFuncReturnType.(ResultType)
+	if !ok {
+		tc.Err(fmt.Sprintf("? operator used in function returning %s, expected a result type", tc.env.currentFuncReturnType))
+		return nil
+	}
+	if !Assignable(funcResultType.ErrType, resultType.ErrType) {
+		tc.Err(fmt.Sprintf("? operator cannot propagate error %s from function returning %s", resultType.ErrType, funcResultType))
+		return nil
+	}
+	return resultType.ValueType
+}
+
 func (tc *TypeChecker) CheckStructLiteralExpr(expr ast.StructLiteralExpr) Type {
 	var structType StructType
 	structTypeValue := tc.InferType(expr.Struct)
@@ -558,8 +1914,17 @@ func (tc *TypeChecker) CheckStructLiteralExpr(expr ast.StructLiteralExpr) Type {
 	for memberName := range structType.Members {
 		assignedMembers[memberName] = false
 	}
+	hasBase := false
+	if expr.Base != nil {
+		baseType := tc.InferType(expr.Base)
+		if baseType != nil && !structType.Equals(baseType) {
+			tc.Err(fmt.Sprintf("cannot update struct %s from a value of type %s", structType.Name, baseType))
+		}
+		hasBase = true
+	}
 	for _, member := range expr.Members {
 		assigneType, ok := structType.Members[member.Name]
+		tc.WarnDeprecatedMember(structType, member.Name)
 		if !ok {
 			tc.Err(fmt.Sprintf("%s is not a member of struct %s", member.Name, structType.Name))
 			continue
@@ -569,17 +1934,17 @@ func (tc *TypeChecker) CheckStructLiteralExpr(expr ast.StructLiteralExpr) Type {
 			continue
 		}
 		assignedValueType := tc.InferType(member.Value)
-		if assignedValueType == nil {
+		if assigneType == nil || assignedValueType == nil {
 			continue
 		}
-		if !assigneType.Equals(assignedValueType) {
-			tc.Err(fmt.Sprintf("cannot assign %s to %s of struct member %s", assignedValueType, assigneType, member.Name))
+		if !Assignable(assigneType, assignedValueType) {
+			tc.Err(fmt.Sprintf("cannot assign %s to %s of struct member %s%s", assignedValueType, assigneType, member.Name, ConformanceDetail(assigneType, assignedValueType)))
 			continue
 		}
 		assignedMembers[member.Name] = true
 	}
 	for memberName, assigned := range assignedMembers {
-		if !assigned {
+		if !assigned && !hasBase && !structType.DefaultedMembers[memberName] {
 			tc.Err(fmt.Sprintf("struct member %s is not assigned a value", memberName))
 		}
 	}
@@ -588,24 +1953,117 @@ func (tc *TypeChecker) CheckStructLiteralExpr(expr ast.StructLiteralExpr) Type {
 
 func (tc *TypeChecker) CheckStructMemberExpr(expr ast.StructMemberExpr) Type {
 	structTypeValue := tc.InferType(expr.Struct)
+	if _, ok := structTypeValue.(OptionalType); ok {
+		tc.Err(fmt.Sprintf("cannot access member %s of optional type %s without ?. or a none check", expr.Member.Value, structTypeValue))
+		return nil
+	}
+	if iface, ok := structTypeValue.(InterfaceType); ok {
+		if method, ok := iface.Methods[expr.Member.Value]; ok {
+			return method
+		}
+		tc.Err(fmt.Sprintf("%s is not a method of interface %s", expr.Member.Value, iface.Name))
+		return nil
+	}
+	if resultType, ok := structTypeValue.(ResultType); ok {
+		switch expr.Member.Value {
+		case ResultIsErrMember:
+			return tc.primitives["bool"]
+		case ResultValueMember:
+			return resultType.ValueType
+		case ResultErrMember:
+			return resultType.ErrType
+		}
+	}
 	structType, ok := structTypeValue.(StructType)
 	if !ok {
 		tc.Err(fmt.Sprintf("expression of type %s cannot be used as a struct", structTypeValue))
 		return nil
 	}
-	memberType, ok := structType.Members[expr.Member.Value]
+	if nestedType, ok := structType.NestedTypes[expr.Member.Value]; ok && tc.IsTypeExpr(expr.Struct) {
+		tc.WarnDeprecatedStruct(nestedType)
+		return nestedType
+	}
+	return tc.LookupStructMember(structType, expr.Member.Value)
+}
+
+func (tc *TypeChecker) IsTypeExpr(expr ast.Expr) bool {
+	if memberExpr, ok := expr.(ast.StructMemberExpr); ok {
+		return tc.IsTypeExpr(memberExpr.Struct)
+	}
+	return tc.IsTypeName(expr)
+}
+
+func (tc *TypeChecker) CheckOptionalMemberExpr(expr ast.OptionalMemberExpr) Type {
+	memberType, optional := tc.LookupOptionalMember(expr)
+	if memberType == nil || !optional {
+		return memberType
+	}
+	return MakeOptional(memberType)
+}
+
+// Wraps t in an optional unless it already is one, so ?. chains stay flat.
+func MakeOptional(t Type) Type {
+	if _, ok := t.(OptionalType); ok {
+		return t
+	}
+	return OptionalType{ElemType: t}
+}
+
+// Returns the type of the member accessed by ?. as if the receiver were not
+// none, and whether the access is optional. The lexer reads r?.m as ?. even
+// when r is a result, where it can only mean r? followed by .m, so on a result
+// it is checked as a try expression and accesses the member of the value.
+func (tc *TypeChecker) LookupOptionalMember(expr ast.OptionalMemberExpr) (Type, bool) {
+	receiverType := tc.InferType(expr.Struct)
+	if receiverType == nil {
+		return nil, false
+	}
+	if resultType, ok := receiverType.(ResultType); ok {
+		valueType := tc.CheckTry(resultType)
+		if valueType == nil {
+			return nil, false
+		}
+		if _, ok := valueType.(OptionalType); ok {
+			tc.Err(fmt.Sprintf("cannot access member %s of optional type %s without ?. or a none check", expr.Member.Value, valueType))
+			return nil, false
+		}
+		return tc.LookupMember(valueType, expr.Member.Value), false
+	}
+	optionalType, ok := receiverType.(OptionalType)
+	if !ok {
+		tc.Err(fmt.Sprintf("?. used on non-optional type %s", receiverType))
+		return nil, false
+	}
+	return tc.LookupMember(optionalType.ElemType, expr.Member.Value), true
+}
+
+// Returns the type of the named member or method of a struct or interface
+// value.
+func (tc *TypeChecker) LookupMember(receiverType Type, name string) Type {
+	if iface, ok := receiverType.(InterfaceType); ok {
+		if method, ok := iface.Methods[name]; ok {
+			return method
+		}
+		tc.Err(fmt.Sprintf("%s is not a method of interface %s", name, iface.Name))
+		return nil
+	}
+	structType, ok := receiverType.(StructType)
 	if !ok {
-		tc.Err(fmt.Sprintf("%s is not a member of struct %s", expr.Member.Value, structType.Name))
+		tc.Err(fmt.Sprintf("expression of type %s cannot be used as a struct", receiverType))
+		return nil
 	}
-	return memberType
+	return tc.LookupStructMember(structType, name)
 }
 
 func (tc *TypeChecker) CheckArrayIndexExpr(expr ast.ArrayIndexExpr) Type {
+	arrayExprType := tc.InferType(expr.Array)
+	if mapType, ok := arrayExprType.(MapType); ok {
+		return tc.CheckMapIndexExpr(mapType, expr)
+	}
 	if !IsNumeric(tc.InferType(expr.Index)) {
 		tc.Err(fmt.Sprintf("array index expression does not result in a numeric type: %s", expr.Index))
 		return nil
 	}
-	arrayExprType := tc.InferType(expr.Array)
 	if arrayExprType == nil {
 		return nil
 	}
@@ -617,13 +2075,47 @@ func (tc *TypeChecker) CheckArrayIndexExpr(expr ast.ArrayIndexExpr) Type {
 	return arrayType.ElemType
 }
 
+func (tc *TypeChecker) CheckSliceExpr(expr ast.SliceExpr) Type {
+	for _, bound := range []ast.Expr{expr.Start, expr.End} {
+		if bound == nil {
+			continue
+		}
+		if boundType := tc.InferType(bound); boundType != nil && !IsInteger(boundType) {
+			tc.Err(fmt.Sprintf("slice bound does not result in an integer type: %s", boundType))
+		}
+	}
+	sliceableType := tc.InferType(expr.Array)
+	if sliceableType == nil {
+		return nil
+	}
+	switch t := sliceableType.(type) {
+	case ArrayType:
+		return t
+	case PrimitiveType:
+		if t.Name == "string" {
+			return t
+		}
+	}
+	tc.Err(fmt.Sprintf("cannot slice non-array type %s", sliceableType))
+	return nil
+}
+
 func (tc *TypeChecker) CheckAssignExpr(expr ast.AssignExpr) Type {
 	assigneType := tc.InferType(expr.Assigne)
+	if ident, ok := expr.Assigne.(ast.IdentExpr); ok && expr.Operator.Type == lexer.ASSIGNMENT {
+		if declaredType, ok := tc.env.LookupDeclaredVarType(ident.Value); ok {
+			assigneType = declaredType
+			tc.env.WidenVar(ident.Value)
+		}
+	}
 	assignedValueType := tc.InferType(expr.AssignedValue)
+	if assigneType == nil || assignedValueType == nil {
+		return nil
+	}
 	switch expr.Operator.Type {
 	case lexer.ASSIGNMENT:
-		if !assigneType.Equals(assignedValueType) {
-			tc.Err(fmt.Sprintf("cannot assign %s to %s", assignedValueType, assigneType))
+		if !Assignable(assigneType, assignedValueType) {
+			tc.Err(fmt.Sprintf("cannot assign %s to %s%s", assignedValueType, assigneType, ConformanceDetail(assigneType, assignedValueType)))
 		}
 	case lexer.PLUS_EQUALS:
 		numeric := IsNumeric(assigneType) && IsNumeric(assignedValueType)
@@ -687,6 +2179,8 @@ func (tc *TypeChecker) CheckUnreachableCode(block ast.BlockStmt) {
 			}
 		case ast.ForStmt:
 			tc.CheckUnreachableCode(s.Body)
+		case ast.ForEachStmt:
+			tc.CheckUnreachableCode(s.Body)
 		}
 	}
 }
@@ -0,0 +1,273 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/lexer"
+	"github.com/ruistola/compiler-proto/parser"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func check(src string) ([]string, []string) {
+	result := Check(parser.Parse(lexer.Tokenize(src)))
+	return result.Errors, result.Warnings
+}
+
+func expectError(t *testing.T, src string, want string) {
+	t.Helper()
+	errs, _ := check(src)
+	for _, err := range errs {
+		if strings.Contains(err, want) {
+			return
+		}
+	}
+	t.Fatalf("expected error containing %q, got %q", want, errs)
+}
+
+func expectNoErrors(t *testing.T, src string) {
+	t.Helper()
+	if errs, _ := check(src); len(errs) > 0 {
+		t.Fatalf("unexpected errors %q", errs)
+	}
+}
+
+func expectWarnings(t *testing.T, src string, want ...string) {
+	t.Helper()
+	errs, warnings := check(src)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors %q", errs)
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("expected %d warnings, got %q", len(want), warnings)
+	}
+	for i, warning := range warnings {
+		if !strings.Contains(warning, want[i]) {
+			t.Fatalf("expected warning containing %q, got %q", want[i], warning)
+		}
+	}
+}
+
+func TestTupleDestructuring(t *testing.T) {
+	expectNoErrors(t, `
+func pair(): (i32, string) {
+    return 1, "one";
+}
+
+func main(): void {
+    let (n, s) = pair();
+    let single: (i32) = n;
+}
+`)
+	expectError(t, `
+func pair(): (i32, i32) {
+    return 1, 2;
+}
+
+func main(): void {
+    let (a, a) = pair();
+}
+`, "duplicate variable a in destructuring")
+}
+
+func TestDeprecation(t *testing.T) {
+	t.Run("shadowing declarations are not deprecated", func(t *testing.T) {
+		expectWarnings(t, `
+@deprecated("gone")
+struct Old {
+    x: i32,
+}
+
+@deprecated
+func helper(): i32 {
+    return 1;
+}
+
+func main(): void {
+    struct Old {
+        y: i32,
+    }
+    func helper(): i32 {
+        return 2;
+    }
+    let old: Old = Old{ y: helper() };
+}
+`)
+	})
+	t.Run("promoted members keep their deprecation", func(t *testing.T) {
+		expectWarnings(t, `
+struct Base {
+    @deprecated("use id")
+    legacyId: i32,
+    id: i32,
+}
+
+struct Derived {
+    Base,
+}
+
+func main(): void {
+    let d: Derived = Derived{ Base: Base{ legacyId: 1, id: 2 } };
+    let id: i32 = d.legacyId;
+}
+`, "deprecated member Base.legacyId: use id", "deprecated member Derived.legacyId: use id")
+	})
+	t.Run("nested structs keep their deprecation", func(t *testing.T) {
+		expectWarnings(t, `
+struct Outer {
+    @deprecated("use Outer.New")
+    struct Old {
+        x: i32,
+    }
+}
+
+func main(): void {
+    let old: Outer.Old = Outer.Old{ x: 1 };
+}
+`, "deprecated struct Outer.Old: use Outer.New", "deprecated struct Outer.Old: use Outer.New")
+	})
+}
+
+func TestExterns(t *testing.T) {
+	result := Check(parser.Parse(lexer.Tokenize(`
+extern func puts(s: string): i32;
+
+@extern("jru_exit")
+extern func exit(code: i32): void;
+
+func main(): void {
+    puts("bye");
+    exit(0);
+}
+`)))
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors %q", result.Errors)
+	}
+	got := []string{}
+	for _, extern := range result.Externs {
+		got = append(got, fmt.Sprintf("%s %s %s", extern.Name, extern.Symbol, extern.Type))
+	}
+	want := []string{"puts puts func(string):i32", "exit jru_exit func(i32):void"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got externs %q, want %q", got, want)
+	}
+
+	expectError(t, `
+@extern("jru_log")
+func log(s: string): void {
+}
+
+func main(): void {
+}
+`, "attribute @extern on function log requires an extern declaration")
+	expectError(t, `
+struct Logger {
+    level: i32,
+
+    @extern("jru_log")
+    func log(s: string): void {
+    }
+}
+
+func main(): void {
+}
+`, "attribute @extern on function log requires an extern declaration")
+}
+
+func TestOptionalMethodCalls(t *testing.T) {
+	expectNoErrors(t, `
+struct Counter {
+    count: i32,
+
+    func plus(n: i32): i32 {
+        return self.count + n;
+    }
+
+    func reset(): void {
+    }
+}
+
+func main(): void {
+    let c: Counter? = none;
+    let n: i32? = c?.plus(1);
+    let total: i32 = c?.plus(2) ?? 0;
+    c?.reset();
+}
+`)
+	expectError(t, `
+struct Counter {
+    count: i32,
+
+    func plus(n: i32): i32 {
+        return self.count + n;
+    }
+}
+
+func main(): void {
+    let c: Counter? = none;
+    let n: i32 = c?.plus(1);
+}
+`, "variable n declared as i32 but initialized with i32?")
+}
+
+func TestOperatorFuncNames(t *testing.T) {
+	expectNoErrors(t, `
+struct Items {
+    count: i32,
+
+    func op_count(): i32 {
+        return self.count;
+    }
+}
+
+func op_total(a: i32, b: i32, c: i32): i32 {
+    return a + b + c;
+}
+
+func main(): void {
+    let items: Items = Items{ count: 2 };
+    let n: i32 = items.op_count() + op_total(1, 2, 3);
+}
+`)
+	expectError(t, `
+func op_add(a: i32, b: i32): i32 {
+    return 0;
+}
+
+func main(): void {
+}
+`, "operator function op_add(i32, i32) must take at least one struct parameter")
+}
+
+func TestStaticAssert(t *testing.T) {
+	expectNoErrors(t, `
+static_assert(2147483646 + 1 > 0, "largest i32");
+static_assert(-2147483647 - 1 < 0, "smallest i32");
+
+func main(): void {
+}
+`)
+	expectError(t, `
+static_assert(2147483647 + 1 > 0, "wraps in i32");
+
+func main(): void {
+}
+`, "constant 2147483648 overflows i32")
+}
+
+func TestDeferNonCall(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1", "deferred expression must be a function call, found literal"},
+		{"x", "deferred expression must be a function call, found identifier x"},
+		{"x + 1", "deferred expression must be a function call, found operator expression"},
+		{"(x = 2)", "deferred expression must be a function call, found assignment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expectError(t, "func main(): void {\n    let x: i32 = 1;\n    defer "+tt.expr+";\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+const typeDecls = `
+struct Arg {
+    pos: i32,
+}
+
+type Args = Arg[];
+newtype UserId = i32;
+newtype Name = string;
+`
+
+func TestTypeDecls(t *testing.T) {
+	expectNoErrors(t, typeDecls+`
+func next(id: UserId): UserId {
+    return id + UserId(1);
+}
+
+func main(): void {
+    let args: Args;
+    let more: Arg[] = args;
+    let back: Args = more;
+    let id: UserId = UserId(42);
+    let raw: i32 = i32(id);
+    let sum: UserId = id + id;
+    let negated: UserId = -id;
+    let later: bool = next(id) > id;
+    let same: bool = id == next(id);
+    let name: Name = Name("root");
+    let s: string = string(name);
+}
+`)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"underlying to newtype", "let id: UserId = 42;", "variable id declared as UserId but initialized with i32"},
+		{"newtype to underlying", "let id: UserId = UserId(42);\n    let raw: i32 = id;", "variable raw declared as i32 but initialized with UserId"},
+		{"newtype argument", "take(42);", "argument 1 type mismatch: expected UserId, found i32"},
+		{"assignment", "let id: UserId = UserId(1);\n    id = 2;", "cannot assign i32 to UserId"},
+		{"mixed arithmetic", "let id: UserId = UserId(1);\n    let n = id + 1;", "invalid operands for +: UserId and i32"},
+		{"mixed comparison", "let id: UserId = UserId(1);\n    let b = id < 2;", "invalid operands for <: UserId and i32"},
+		{"string newtype arithmetic", "let name: Name = Name(\"a\");\n    let both = name + name;", "invalid operands for +: Name and Name"},
+		{"redeclared", "type UserId = i32;", "redeclared type UserId in the same scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, typeDecls+"\nfunc take(id: UserId): void {\n}\n\nfunc main(): void {\n    "+tt.body+"\n}\n", tt.want)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package typechecker
+
+import (
+	"fmt"
+	"github.com/ruistola/compiler-proto/ast"
+)
+
+func IsDeclaration(stmt ast.Stmt) bool {
+	switch stmt.(type) {
+	case ast.VarDeclStmt,
+		ast.TupleDeclStmt,
+		ast.FuncDeclStmt,
+		ast.StructDeclStmt,
+		ast.InterfaceDeclStmt,
+		ast.TypeDeclStmt,
+		ast.StaticAssertStmt:
+		return true
+	}
+	return false
+}
+
+// ValidateProgram checks the structure of a program before type checking: the
+// top level may only contain declarations, declarations may not appear where
+// they could never be used, and there must be exactly one valid main function.
+func (tc *TypeChecker) ValidateProgram(program ast.BlockStmt) {
+	mains := 0
+	for _, stmt := range program.Body {
+		if !IsDeclaration(stmt) {
+			tc.Err(fmt.Sprintf("%s is not allowed at the top level, only declarations are", describeStmt(stmt)))
+			continue
+		}
+		if funcDecl, ok := stmt.(ast.FuncDeclStmt); ok && funcDecl.Name == "main" {
+			mains++
+			if mains > 1 {
+				tc.Err("main function declared more than once")
+				continue
+			}
+			tc.ValidateMainFunc(funcDecl)
+		}
+		tc.ValidateNestedStmts(stmt)
+	}
+	if mains == 0 {
+		tc.Err("program has no main function")
+	}
+}
+
+func (tc *TypeChecker) ValidateMainFunc(stmt ast.FuncDeclStmt) {
+	if stmt.Extern {
+		tc.Err("main function cannot be extern")
+		return
+	}
+	for _, param := range stmt.Parameters {
+		if param.Default != nil {
+			tc.Err(fmt.Sprintf("parameter %s of main function cannot have a default value", param.Name))
+			return
+		}
+		if param.Variadic {
+			tc.Err(fmt.Sprintf("parameter %s of main function cannot be variadic", param.Name))
+			return
+		}
+	}
+	validParams := len(stmt.Parameters) == 0 ||
+		(len(stmt.Parameters) == 2 &&
+			isNamedType(stmt.Parameters[0].Type, "i32") &&
+			isArrayOfNamedType(stmt.Parameters[1].Type, "string"))
+	if !validParams {
+		tc.Err("main function must take no parameters or (argc: i32, argv: string[])")
+	}
+	if stmt.ReturnType != nil && !isNamedType(stmt.ReturnType, "void") && !isNamedType(stmt.ReturnType, "i32") {
+		tc.Err("main function must return void or i32")
+	}
+}
+
+func isNamedType(t ast.Type, name string) bool {
+	namedType, ok := t.(ast.NamedType)
+	return ok && namedType.TypeName == name
+}
+
+func isArrayOfNamedType(t ast.Type, name string) bool {
+	arrayType, ok := t.(ast.ArrayType)
+	return ok && isNamedType(arrayType.UnderlyingType, name)
+}
+
+// Checks the statements nested inside stmt for declarations in positions
+// where they are not allowed: main anywhere but the top level, the init
+// statement of a for loop other than a variable declaration, and the
+// unbraced branch of an if statement.
+func (tc *TypeChecker) ValidateNestedStmts(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case ast.BlockStmt:
+		tc.ValidateBlockStmts(s)
+	case ast.FuncDeclStmt:
+		tc.ValidateBlockStmts(s.Body)
+	case ast.StructDeclStmt:
+		for _, method := range s.Methods {
+			tc.ValidateBlockStmts(method.Body)
+		}
+		for _, nested := range s.NestedStructs {
+			tc.ValidateNestedStmts(nested)
+		}
+	case ast.IfStmt:
+		for _, branch := range []ast.Stmt{s.Then, s.Else} {
+			if branch == nil {
+				continue
+			}
+			if IsDeclaration(branch) {
+				tc.Err(fmt.Sprintf("%s is not allowed as the body of an if statement without braces", describeStmt(branch)))
+				continue
+			}
+			tc.ValidateNestedStmts(branch)
+		}
+	case ast.ForStmt:
+		switch s.Init.(type) {
+		case nil, ast.VarDeclStmt, ast.TupleDeclStmt, ast.ExpressionStmt:
+		default:
+			tc.Err(fmt.Sprintf("%s is not allowed as the init statement of a for loop", describeStmt(s.Init)))
+		}
+		tc.ValidateBlockStmts(s.Body)
+	case ast.ForEachStmt:
+		tc.ValidateBlockStmts(s.Body)
+	}
+}
+
+func (tc *TypeChecker) ValidateBlockStmts(block ast.BlockStmt) {
+	for _, stmt := range block.Body {
+		if funcDecl, ok := stmt.(ast.FuncDeclStmt); ok && funcDecl.Name == "main" {
+			tc.Err("main function must be declared at the top level")
+		}
+		tc.ValidateNestedStmts(stmt)
+	}
+}
+
+func describeStmt(stmt ast.Stmt) string {
+	switch s := stmt.(type) {
+	case ast.VarDeclStmt, ast.TupleDeclStmt:
+		return "variable declaration"
+	case ast.FuncDeclStmt:
+		return fmt.Sprintf("function declaration %s", s.Name)
+	case ast.StructDeclStmt:
+		return fmt.Sprintf("struct declaration %s", s.Name)
+	case ast.InterfaceDeclStmt:
+		return fmt.Sprintf("interface declaration %s", s.Name)
+	case ast.TypeDeclStmt:
+		return fmt.Sprintf("type declaration %s", s.Name)
+	case ast.StaticAssertStmt:
+		return "static_assert"
+	case ast.ExpressionStmt:
+		return "expression statement"
+	case ast.BlockStmt:
+		return "block"
+	case ast.IfStmt:
+		return "if statement"
+	case ast.ForStmt, ast.ForEachStmt:
+		return "for loop"
+	case ast.ReturnStmt:
+		return "return statement"
+	case ast.DeferStmt:
+		return "defer statement"
+	case ast.AssertStmt:
+		return "assert statement"
+	default:
+		return fmt.Sprintf("%T", stmt)
+	}
+}
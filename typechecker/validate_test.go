@@ -0,0 +1,79 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+func TestValidateProgram(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			"top level expression",
+			"func main(): void {\n}\nmain();\n",
+			"expression statement is not allowed at the top level, only declarations are",
+		},
+		{
+			"missing main",
+			"func helper(): void {\n}\n",
+			"program has no main function",
+		},
+		{
+			"duplicate main",
+			"func main(): void {\n}\nfunc main(): void {\n}\n",
+			"main function declared more than once",
+		},
+		{
+			"nested main",
+			"func main(): void {\n    func main(): void {\n    }\n}\n",
+			"main function must be declared at the top level",
+		},
+		{
+			"extern main",
+			"extern func main(): void;\n",
+			"main function cannot be extern",
+		},
+		{
+			"wrong parameters",
+			"func main(argc: i32): void {\n}\n",
+			"main function must take no parameters or (argc: i32, argv: string[])",
+		},
+		{
+			"wrong return type",
+			"func main(): string {\n    return \"\";\n}\n",
+			"main function must return void or i32",
+		},
+		{
+			"defaulted parameter",
+			"func main(argc: i32 = 0, argv: string[]): void {\n}\n",
+			"parameter argc of main function cannot have a default value",
+		},
+		{
+			"variadic parameter",
+			"func main(argv: ...string): void {\n}\n",
+			"parameter argv of main function cannot be variadic",
+		},
+		{
+			"function declaration in for init",
+			"func main(): void {\n    for (func f(): void {} true; f()) {\n    }\n}\n",
+			"function declaration f is not allowed as the init statement of a for loop",
+		},
+		{
+			"declaration as unbraced if body",
+			"func main(): void {\n    if (true) let x: i32 = 1;\n}\n",
+			"variable declaration is not allowed as the body of an if statement without braces",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, tt.src, tt.want)
+		})
+	}
+}
+
+func TestValidMainSignatures(t *testing.T) {
+	expectNoErrors(t, "func main(): void {\n}\n")
+	expectNoErrors(t, "func main(argc: i32, argv: string[]): i32 {\n    return 0;\n}\n")
+}
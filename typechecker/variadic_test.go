@@ -0,0 +1,106 @@
+package typechecker
+
+import (
+	"testing"
+)
+
+const variadicDecls = `
+func sum(args: ...i32): i32 {
+    let total: i32 = 0;
+    for (arg in args) {
+        total = total + arg;
+    }
+    return total;
+}
+
+func log(prefix: string = "log", args: ...i32): void {
+}
+`
+
+func TestVariadicCalls(t *testing.T) {
+	expectNoErrors(t, variadicDecls+`
+func total(xs: i32[]): i32 {
+    log("spread", xs...);
+    return sum(xs...);
+}
+
+func main(): void {
+    let zero: i32 = sum();
+    let six: i32 = sum(1, 2, 3);
+    log();
+    log("values", 1, 2);
+    log(prefix: "named");
+    let f: func(string, ...i32): void = log;
+    f("through a variable", 7, 8, 9);
+}
+`)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"element type", `sum(1, "two");`, "argument 2 type mismatch: expected i32, found string"},
+		{"spread type", `let xs: string[]; sum(xs...);`, "argument 1 type mismatch: expected i32[], found string[]"},
+		{"spread mixed with elements", `let xs: i32[]; sum(1, xs...);`, "spread argument cannot be combined with other variadic arguments"},
+		{"spread before elements", `let xs: i32[]; sum(xs..., 1);`, "spread argument cannot be combined with other variadic arguments"},
+		{"variadic by name", `sum(args: 1);`, "variadic parameter args cannot be passed by name"},
+		{"spread to a fixed parameter", `let xs: string[]; log(xs...);`, "spread argument is only allowed for the variadic parameter of a function call"},
+		{"spread to a non-variadic function", `let xs: i32[]; main(xs...);`, "wrong number of arguments"},
+		{"array to a variadic parameter", `let xs: i32[]; sum(xs);`, "argument 1 type mismatch: expected i32, found i32[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectError(t, variadicDecls+"\nfunc main(): void {\n    "+tt.body+"\n}\n", tt.want)
+		})
+	}
+}
+
+func TestVariadicDeclarations(t *testing.T) {
+	expectError(t, `
+func f(args: ...i32 = 1): void {
+}
+
+func main(): void {
+}
+`, "variadic parameter args of function f cannot have a default value")
+	expectNoErrors(t, `
+extern func print(args: ...i32): void;
+
+func main(): void {
+    print(1, 2);
+}
+`)
+	expectError(t, `
+extern func print(args: ...i32[]): void;
+
+func main(): void {
+}
+`, "parameter args of extern function print has type i32[] which cannot cross the FFI boundary")
+}
+
+func TestVariadicFuncType(t *testing.T) {
+	i32 := PrimitiveType{Name: "i32"}
+	str := PrimitiveType{Name: "string"}
+	void := PrimitiveType{Name: "void"}
+	variadic := FuncType{
+		ReturnType: void,
+		ParamTypes: []Type{str, ArrayType{ElemType: i32}},
+		Variadic:   true,
+	}
+	array := FuncType{
+		ReturnType: void,
+		ParamTypes: []Type{str, ArrayType{ElemType: i32}},
+	}
+	if got := variadic.String(); got != "func(string,...i32):void" {
+		t.Fatalf("unexpected variadic function type %s", got)
+	}
+	if got := array.String(); got != "func(string,i32[]):void" {
+		t.Fatalf("unexpected function type %s", got)
+	}
+	if variadic.Equals(array) || array.Equals(variadic) {
+		t.Fatalf("variadic and array parameters must be different types")
+	}
+	if !variadic.Equals(FuncType{ReturnType: void, ParamTypes: []Type{str, ArrayType{ElemType: i32}}, Variadic: true}) {
+		t.Fatalf("identical variadic function types must be equal")
+	}
+}